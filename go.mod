@@ -4,6 +4,7 @@ go 1.24.3
 
 require (
 	github.com/gdamore/tcell/v2 v2.8.1
+	github.com/pkoukk/tiktoken-go v0.1.7
 	github.com/rivo/tview v0.0.0-20250625164341-a4a78f1e05cb
 )
 
@@ -13,7 +14,6 @@ require (
 	github.com/google/uuid v1.3.0 // indirect
 	github.com/lucasb-eyer/go-colorful v1.2.0 // indirect
 	github.com/mattn/go-runewidth v0.0.16 // indirect
-	github.com/pkoukk/tiktoken-go v0.1.7 // indirect
 	github.com/rivo/uniseg v0.4.7 // indirect
 	golang.org/x/sys v0.33.0 // indirect
 	golang.org/x/term v0.32.0 // indirect
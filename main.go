@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"os"
 	"path/filepath"
 	"strings"
@@ -10,73 +12,157 @@ import (
 	"llmShortTermMemory/model"
 )
 
-func init() {
-	model.ApiKey = os.Getenv("OPENAI_API_KEY")
-	if model.ApiKey == "" {
-		data, err := os.ReadFile("OPENAI_API_KEY")
-		if err == nil {
-			model.ApiKey = strings.TrimSpace(string(data))
-		} else {
-			execPath, _ := os.Executable()
-			execDir := filepath.Dir(execPath)
-			configPath := filepath.Join(execDir, "OPENAI_API_KEY")
-
-			data, err := os.ReadFile(configPath)
-			if err == nil {
-				model.ApiKey = strings.TrimSpace(string(data))
-			}
+// 依序從環境變數、當前目錄、執行檔目錄讀取設定
+func readConfig(name string, useEnv bool) string {
+	if useEnv {
+		if value := os.Getenv(name); value != "" {
+			return value
 		}
 	}
 
-	data, err := os.ReadFile("INSTRUCTION_CONVERSATION")
+	data, err := os.ReadFile(name)
 	if err == nil {
-		model.InstructionConversation = strings.TrimSpace(string(data))
-	} else {
-		execPath, _ := os.Executable()
-		execDir := filepath.Dir(execPath)
-		instructionPath := filepath.Join(execDir, "INSTRUCTION_CONVERSATION")
-
-		data, err := os.ReadFile(instructionPath)
-		if err == nil {
-			model.InstructionConversation = strings.TrimSpace(string(data))
-		} else {
-			model.InstructionConversation = ""
-		}
+		return strings.TrimSpace(string(data))
 	}
 
-	data, err = os.ReadFile("INSTRUCTION_SUMMARY")
+	execPath, _ := os.Executable()
+	execDir := filepath.Dir(execPath)
+	configPath := filepath.Join(execDir, name)
+
+	data, err = os.ReadFile(configPath)
 	if err == nil {
-		model.InstructionSummary = strings.TrimSpace(string(data))
-	} else {
-		execPath, _ := os.Executable()
-		execDir := filepath.Dir(execPath)
-		instructionPath := filepath.Join(execDir, "INSTRUCTION_SUMMARY")
-
-		data, err := os.ReadFile(instructionPath)
-		if err == nil {
-			model.InstructionSummary = strings.TrimSpace(string(data))
-		} else {
-			model.InstructionSummary = ""
-		}
+		return strings.TrimSpace(string(data))
 	}
+	return ""
+}
+
+func init() {
+	model.ApiKey = readConfig("OPENAI_API_KEY", true)
+	model.AnthropicApiKey = readConfig("ANTHROPIC_API_KEY", true)
+	model.GeminiApiKey = readConfig("GEMINI_API_KEY", true)
+	// 本地 Ollama 通常不需要金鑰，只在反向代理要求驗證時設定
+	model.OllamaApiKey = readConfig("OLLAMA_API_KEY", true)
+	model.InstructionConversation = readConfig("INSTRUCTION_CONVERSATION", false)
+	model.InstructionSummary = readConfig("INSTRUCTION_SUMMARY", false)
 }
 
 func main() {
 	var appState *model.Frame
 
 	// 檢查命令列參數
-	useOldUI := false
-	for _, arg := range os.Args[1:] {
-		if arg == "--old" {
-			useOldUI = true
-			break
+	useOldUI := flag.Bool("old", false, "use traditional full-history memory mode")
+	providerName := flag.String("provider", "openai", "openai | anthropic | gemini | ollama")
+	baseURL := flag.String("base-url", "", "custom API endpoint, e.g. http://localhost:8080/v1 for llama.cpp (openai defaults to OPENAI_BASE_URL)")
+	largeModel := flag.String("model", "", "conversation model")
+	smallModel := flag.String("small-model", "", "summary model (defaults to --model for ollama)")
+	contextWindow := flag.Int("context-window", 0, "context window of --model in tokens")
+	embedderName := flag.String("embedder", "hash", "hash | openai | none, semantic similarity backend")
+	embeddingModel := flag.String("embedding-model", "text-embedding-3-small", "model for --embedder openai")
+	embeddingURL := flag.String("embedding-url", "", "custom embeddings endpoint, e.g. http://localhost:11434/v1")
+	budget := flag.Float64("budget", 0, "session budget in USD, 0 for unlimited")
+	budgetWarn := flag.Bool("budget-warn", false, "only warn instead of blocking when the budget is exceeded")
+	sessionName := flag.String("session", "", "save and resume the conversation under this name")
+	sessionDir := flag.String("session-dir", "sessions", "directory for saved sessions")
+	memoryTokens := flag.Int("memory-tokens", model.DefaultMemoryTokenBudget, "token budget for retrieved history records")
+	flag.Parse()
+
+	if priceTable := readConfig("PRICE_TABLE", false); priceTable != "" {
+		if err := model.LoadPriceTable([]byte(priceTable)); err != nil {
+			panic(err)
 		}
 	}
 
-	if useOldUI {
-		appState = model.CreateOldUI()
+	var provider model.Provider
+	defaultLarge, defaultSmall := "gpt-4o", "gpt-4o-mini"
+	switch *providerName {
+	case "anthropic", "claude":
+		anthropic := model.NewAnthropic(model.AnthropicApiKey)
+		if *baseURL != "" {
+			anthropic.BaseURL = *baseURL
+		}
+		provider = anthropic
+		defaultLarge, defaultSmall = "claude-sonnet-4-20250514", "claude-3-5-haiku-20241022"
+	case "gemini":
+		gemini := model.NewGemini(model.GeminiApiKey)
+		if *baseURL != "" {
+			gemini.BaseURL = *baseURL
+		}
+		provider = gemini
+		defaultLarge, defaultSmall = "gemini-2.5-pro", "gemini-2.5-flash"
+	case "ollama":
+		ollama := model.NewOllama(*baseURL)
+		ollama.ApiKey = model.OllamaApiKey
+		provider = ollama
+		defaultLarge, defaultSmall = "llama3.1", ""
+	default:
+		// OPENAI_BASE_URL 只套用於 OpenAI 相容端點，不影響其他供應商
+		openai := model.NewOpenAI(model.ApiKey)
+		if *baseURL == "" {
+			*baseURL = os.Getenv("OPENAI_BASE_URL")
+		}
+		if *baseURL != "" {
+			openai.BaseURL = *baseURL
+		}
+		provider = openai
+	}
+
+	provider = model.WithRetry(provider, model.DefaultRetryPolicy())
+
+	if *largeModel == "" {
+		*largeModel = defaultLarge
+	}
+	if *smallModel == "" {
+		*smallModel = defaultSmall
+	}
+	if *smallModel == "" {
+		*smallModel = *largeModel
+	}
+	if *contextWindow > 0 {
+		model.ContextWindowList[*largeModel] = *contextWindow
+	}
+
+	comparerOptions := make([]model.ComparerOption, 0)
+	if scoring := readConfig("SCORING_CONFIG", false); scoring != "" {
+		config, err := model.ParseScoringConfig([]byte(scoring))
+		if err != nil {
+			panic(err)
+		}
+		comparerOptions = append(comparerOptions, model.WithScoringConfig(config))
+	}
+
+	switch *embedderName {
+	case "openai":
+		embedder := model.NewOpenAIEmbedder(model.ApiKey, *embeddingModel)
+		if *embeddingURL != "" {
+			embedder.BaseURL = *embeddingURL
+		}
+		comparerOptions = append(comparerOptions, model.WithEmbedder(embedder))
+	case "hash":
+		comparerOptions = append(comparerOptions, model.WithEmbedder(model.NewHashEmbedder(256)))
+	}
+
+	if *useOldUI {
+		appState = model.CreateOldUI(provider, *largeModel, *smallModel)
 	} else {
-		appState = model.CreateUI()
+		appState = model.CreateUI(provider, *largeModel, *smallModel, comparerOptions...)
+	}
+
+	appState.Budget = model.Budget{
+		Limit:    *budget,
+		WarnOnly: *budgetWarn,
+	}
+	appState.MemoryBudget = *memoryTokens
+
+	// 向量與 Embedder 綁定，換用不同 Embedder 時還原後重新計算
+	appState.Sessions = model.NewSessionStore(*sessionDir)
+	appState.EmbedderID = *embedderName
+	if *embedderName == "openai" {
+		appState.EmbedderID += ":" + *embeddingModel
+	}
+	if *sessionName != "" {
+		if err := appState.OpenSession(*sessionName); err != nil {
+			panic(err)
+		}
 	}
 
 	appState.Input.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
@@ -85,14 +171,18 @@ func main() {
 			return nil // 阻止 Tab 鍵在輸入框中觸發
 		}
 		if event.Key() == tcell.KeyEnter && len(text) > 2 && (strings.HasSuffix(text, "$$") || strings.HasSuffix(text, "＄＄")) {
+			// 進行中的回合結束前保留輸入內容
+			if appState.Busy() {
+				return nil
+			}
 			text = strings.TrimSuffix(text, "$$")
 			text = strings.TrimSuffix(text, "＄＄")
 			appState.Input.SetText("", true)
 
-			if useOldUI {
-				appState.OldAPIHandler(text)
+			if *useOldUI {
+				appState.OldAPIHandler(context.Background(), text)
 			} else {
-				appState.APIHandler(text)
+				appState.APIHandler(context.Background(), text)
 			}
 
 			return nil
@@ -105,10 +195,31 @@ func main() {
 		switch event.Key() {
 		case tcell.KeyCtrlC:
 			appState.App.Stop()
+		case tcell.KeyF3:
+			// 開關對話列表
+			if !*useOldUI {
+				appState.ToggleSessionBrowser()
+				return nil
+			}
+		case tcell.KeyF2:
+			// 切換檢索除錯面板
+			if !*useOldUI {
+				appState.ToggleRetrieval()
+				return nil
+			}
+		case tcell.KeyEscape, tcell.KeyCtrlX:
+			// 中斷生成中的回覆
+			if appState.Cancel() {
+				return nil
+			}
 		case tcell.KeyTab:
+			// 對話列表開啟時交由列表處理
+			if appState.SessionBrowserVisible() {
+				return event
+			}
 			// Tab 切換焦點
 			currentFocus := appState.App.GetFocus()
-			if useOldUI {
+			if *useOldUI {
 				// 舊版 UI 只有 Input 和 Conversation
 				if currentFocus == appState.Input {
 					appState.App.SetFocus(appState.Conversation)
@@ -117,10 +228,13 @@ func main() {
 				}
 			} else {
 				// 新版 UI 有 Input、Conversation 和 Summary
+				// 除錯面板顯示時一併加入切換
 				if currentFocus == appState.Input {
 					appState.App.SetFocus(appState.Conversation)
 				} else if currentFocus == appState.Conversation {
 					appState.App.SetFocus(appState.Summary)
+				} else if currentFocus == appState.Summary && appState.RetrievalVisible() {
+					appState.App.SetFocus(appState.Retrieval)
 				} else {
 					appState.App.SetFocus(appState.Input)
 				}
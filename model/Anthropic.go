@@ -0,0 +1,233 @@
+package model
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+type anthropicToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+type anthropicRequest struct {
+	Model      string               `json:"model"`
+	System     string               `json:"system,omitempty"`
+	Messages   []anthropicMessage   `json:"messages"`
+	MaxTokens  int                  `json:"max_tokens"`
+	Stream     bool                 `json:"stream"`
+	Tools      []anthropicTool      `json:"tools,omitempty"`
+	ToolChoice *anthropicToolChoice `json:"tool_choice,omitempty"`
+}
+
+type anthropicUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+type anthropicEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+	Usage anthropicUsage `json:"usage"`
+	Delta struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+	} `json:"delta"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type Anthropic struct {
+	ApiKey    string
+	BaseURL   string
+	Version   string
+	MaxTokens int
+	Client    *http.Client
+}
+
+func NewAnthropic(apiKey string) *Anthropic {
+	return &Anthropic{
+		ApiKey:    apiKey,
+		BaseURL:   "https://api.anthropic.com",
+		Version:   "2023-06-01",
+		MaxTokens: 4096,
+		Client:    newHTTPClient(),
+	}
+}
+
+// Messages API 的 system 是頂層欄位，而非訊息角色
+// 將所有 system 訊息依序合併，其餘訊息合併相鄰同角色以符合 user/assistant 交替規則
+func toAnthropicMessages(msgList []Message) (string, []anthropicMessage) {
+	systemList := make([]string, 0)
+	messages := make([]anthropicMessage, 0, len(msgList))
+
+	for _, msg := range msgList {
+		if msg.Role == "system" {
+			if content := strings.TrimSpace(msg.Content); content != "" {
+				systemList = append(systemList, content)
+			}
+			continue
+		}
+
+		if len(messages) > 0 && messages[len(messages)-1].Role == msg.Role {
+			messages[len(messages)-1].Content += "\n\n" + msg.Content
+			continue
+		}
+		messages = append(messages, anthropicMessage{
+			Role:    msg.Role,
+			Content: msg.Content,
+		})
+	}
+
+	return strings.Join(systemList, "\n\n"), messages
+}
+
+func (a *Anthropic) Chat(ctx context.Context, chatReq ChatRequest) (*ChatResponse, error) {
+	system, messages := toAnthropicMessages(chatReq.Messages)
+
+	reqBody := anthropicRequest{
+		Model:     chatReq.Model,
+		System:    system,
+		Messages:  messages,
+		MaxTokens: a.MaxTokens,
+		Stream:    true,
+	}
+	// Messages API 沒有 response_format，以強制呼叫工具取得符合結構的輸入參數
+	if chatReq.Schema != nil {
+		reqBody.Tools = []anthropicTool{{
+			Name:        chatReq.Schema.Name,
+			Description: "Return the result as structured data.",
+			InputSchema: chatReq.Schema.Schema,
+		}}
+		reqBody.ToolChoice = &anthropicToolChoice{
+			Type: "tool",
+			Name: chatReq.Schema.Name,
+		}
+	}
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(a.BaseURL, "/")+"/v1/messages", strings.NewReader(string(body)))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", a.ApiKey)
+	req.Header.Set("anthropic-version", a.Version)
+
+	res, err := a.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, newAPIError(res)
+	}
+
+	var result strings.Builder
+	usage := &Usage{}
+	reader := bufio.NewReader(res.Body)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// 事件類型同時存在於 event: 行與 data 的 type 欄位，只需解析 data
+		lineStr := strings.TrimSpace(string(line))
+		if !strings.HasPrefix(lineStr, "data:") {
+			continue
+		}
+		jsonData := strings.TrimSpace(strings.TrimPrefix(lineStr, "data:"))
+
+		var event anthropicEvent
+		if err := json.Unmarshal([]byte(jsonData), &event); err != nil {
+			continue
+		}
+
+		switch event.Type {
+		case "message_start":
+			// input_tokens 不含快取部分，合計後與其他供應商的 prompt 定義一致
+			input := event.Message.Usage
+			usage.PromptTokens = input.InputTokens + input.CacheCreationInputTokens + input.CacheReadInputTokens
+			usage.CachedTokens = input.CacheReadInputTokens
+			usage.CompletionTokens = input.OutputTokens
+		case "message_delta":
+			// output_tokens 為累計值
+			usage.CompletionTokens = event.Usage.OutputTokens
+		case "content_block_delta":
+			// 工具輸入以 input_json_delta 分段送出，串起來即為結構化結果
+			delta := event.Delta.Text
+			if event.Delta.Type == "input_json_delta" {
+				delta = event.Delta.PartialJSON
+			}
+			if delta == "" {
+				continue
+			}
+			result.WriteString(delta)
+			if chatReq.OnDelta != nil {
+				chatReq.OnDelta(delta)
+			}
+		case "error":
+			return nil, &APIError{
+				Kind:    anthropicErrorKind(event.Error.Type),
+				Message: event.Error.Type + ": " + event.Error.Message,
+			}
+		case "message_stop":
+			return &ChatResponse{
+				Content: result.String(),
+				Usage:   usage,
+			}, nil
+		}
+	}
+
+	return &ChatResponse{
+		Content: result.String(),
+		Usage:   usage,
+	}, nil
+}
+
+// 串流中途的錯誤沒有狀態碼，依錯誤類型分類
+func anthropicErrorKind(errType string) APIErrorKind {
+	switch errType {
+	case "rate_limit_error":
+		return ErrRateLimit
+	case "overloaded_error", "api_error":
+		return ErrServer
+	case "authentication_error", "permission_error":
+		return ErrAuth
+	default:
+		return ErrUnknown
+	}
+}
@@ -0,0 +1,198 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// 依序回放 SSE 事件，並保存收到的請求內容
+func newAnthropicServer(t *testing.T, eventList []string, request *anthropicRequest) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			t.Errorf("path = %q, want /v1/messages", r.URL.Path)
+		}
+		if r.Header.Get("x-api-key") != "test-key" {
+			t.Errorf("x-api-key = %q, want test-key", r.Header.Get("x-api-key"))
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		if request != nil {
+			if err := json.Unmarshal(body, request); err != nil {
+				t.Errorf("invalid request body: %v", err)
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range eventList {
+			var data struct {
+				Type string `json:"type"`
+			}
+			json.Unmarshal([]byte(event), &data)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", data.Type, event)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestAnthropic(baseURL string) *Anthropic {
+	anthropic := NewAnthropic("test-key")
+	anthropic.BaseURL = baseURL
+	return anthropic
+}
+
+func TestAnthropicChatStream(t *testing.T) {
+	var request anthropicRequest
+	server := newAnthropicServer(t, []string{
+		`{"type":"message_start","message":{"usage":{"input_tokens":10,"cache_creation_input_tokens":20,"cache_read_input_tokens":30,"output_tokens":1}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":", world"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":15}}`,
+		`{"type":"message_stop"}`,
+	}, &request)
+
+	// 與 APIHandler 相同：系統提示、概要、相關歷史三段 system 訊息
+	deltaList := make([]string, 0)
+	response, err := newTestAnthropic(server.URL).Chat(context.Background(), ChatRequest{
+		Model: "claude-test",
+		Messages: []Message{
+			{Role: "system", Content: "system prompt"},
+			{Role: "system", Content: "summary"},
+			{Role: "system", Content: "relevant history"},
+			{Role: "user", Content: "question"},
+		},
+		OnDelta: func(delta string) {
+			deltaList = append(deltaList, delta)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if request.System != "system prompt\n\nsummary\n\nrelevant history" {
+		t.Errorf("system = %q", request.System)
+	}
+	if len(request.Messages) != 1 || request.Messages[0].Role != "user" || request.Messages[0].Content != "question" {
+		t.Errorf("messages = %+v, want a single user message", request.Messages)
+	}
+	if !request.Stream || request.Tools != nil || request.ToolChoice != nil {
+		t.Errorf("stream = %v, tools = %v, tool_choice = %v", request.Stream, request.Tools, request.ToolChoice)
+	}
+
+	if response.Content != "Hello, world" {
+		t.Errorf("content = %q", response.Content)
+	}
+	if strings.Join(deltaList, "|") != "Hello|, world" {
+		t.Errorf("deltas = %q", deltaList)
+	}
+
+	// prompt 包含快取寫入與讀取，completion 以 message_delta 的累計值為準
+	want := Usage{PromptTokens: 60, CompletionTokens: 15, CachedTokens: 30}
+	if *response.Usage != want {
+		t.Errorf("usage = %+v, want %+v", *response.Usage, want)
+	}
+}
+
+func TestAnthropicChatSchema(t *testing.T) {
+	var request anthropicRequest
+	server := newAnthropicServer(t, []string{
+		`{"type":"message_start","message":{"usage":{"input_tokens":5,"output_tokens":1}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"summary","input":{}}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"core_discussion\": \"te"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"st\"}"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":8}}`,
+		`{"type":"message_stop"}`,
+	}, &request)
+
+	schema := &JSONSchema{Name: "summary", Schema: SchemaOf(Summary{})}
+	response, err := newTestAnthropic(server.URL).Chat(context.Background(), ChatRequest{
+		Model:    "claude-test",
+		Messages: []Message{{Role: "user", Content: "summarize"}},
+		Schema:   schema,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(request.Tools) != 1 || request.Tools[0].Name != "summary" || request.Tools[0].InputSchema["type"] != "object" {
+		t.Errorf("tools = %+v", request.Tools)
+	}
+	if request.ToolChoice == nil || request.ToolChoice.Type != "tool" || request.ToolChoice.Name != "summary" {
+		t.Errorf("tool_choice = %+v", request.ToolChoice)
+	}
+
+	var summary Summary
+	if err := json.Unmarshal([]byte(response.Content), &summary); err != nil {
+		t.Fatalf("content %q is not JSON: %v", response.Content, err)
+	}
+	if summary.CoreDiscussion != "test" {
+		t.Errorf("core_discussion = %q", summary.CoreDiscussion)
+	}
+	if response.Usage.CompletionTokens != 8 {
+		t.Errorf("completion tokens = %d, want 8", response.Usage.CompletionTokens)
+	}
+}
+
+func TestAnthropicChatStreamError(t *testing.T) {
+	server := newAnthropicServer(t, []string{
+		`{"type":"message_start","message":{"usage":{"input_tokens":5,"output_tokens":1}}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"partial"}}`,
+		`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+	}, nil)
+
+	_, err := newTestAnthropic(server.URL).Chat(context.Background(), ChatRequest{
+		Model:    "claude-test",
+		Messages: []Message{{Role: "user", Content: "question"}},
+	})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want *APIError", err)
+	}
+	if apiErr.Kind != ErrServer || !apiErr.Retryable() {
+		t.Errorf("kind = %v, retryable = %v, want retryable server error", apiErr.Kind, apiErr.Retryable())
+	}
+	if apiErr.Message != "overloaded_error: Overloaded" {
+		t.Errorf("message = %q", apiErr.Message)
+	}
+}
+
+func TestToAnthropicMessages(t *testing.T) {
+	system, messages := toAnthropicMessages([]Message{
+		{Role: "system", Content: "first"},
+		{Role: "user", Content: "a"},
+		{Role: "system", Content: "  "},
+		{Role: "user", Content: "b"},
+		{Role: "assistant", Content: "c"},
+		{Role: "system", Content: "second"},
+	})
+
+	if system != "first\n\nsecond" {
+		t.Errorf("system = %q", system)
+	}
+	want := []anthropicMessage{
+		{Role: "user", Content: "a\n\nb"},
+		{Role: "assistant", Content: "c"},
+	}
+	if len(messages) != len(want) {
+		t.Fatalf("messages = %+v, want %+v", messages, want)
+	}
+	for i := range want {
+		if messages[i] != want[i] {
+			t.Errorf("messages[%d] = %+v, want %+v", i, messages[i], want[i])
+		}
+	}
+}
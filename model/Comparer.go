@@ -2,10 +2,10 @@
 package model
 
 import (
-	"fmt"
+	"context"
 	"math"
 	"sort"
-	"strings"
+	"sync"
 	"time"
 )
 
@@ -15,218 +15,622 @@ type ConversationRecord struct {
 	User    string    `json:"user"`
 	Content string    `json:"content"`
 	Keyword []string  `json:"keyword"`
+	// 同一回合（使用者提問與其回覆）共用的編號
+	TurnID int `json:"turn_id"`
+	// 被檢索並注入提示的次數與最後一次時間，記憶強度越高衰減越慢
+	RecallCount    int       `json:"recall_count"`
+	LastRecalledAt time.Time `json:"last_recalled_at"`
+	Strength       float64   `json:"strength"`
+	// 小寫詞彙集合與不重複詞數，新增時計算一次供語義相似度使用
+	wordSet   map[string]bool
+	wordCount int
+	// 語義向量，尚未完成或失敗時為 nil，改用詞彙重疊
+	vector []float32
 }
 
 type SearchResult struct {
 	Record *ConversationRecord `json:"record"`
 	Score  float64             `json:"score"`
+	// 加權前的各項分數，用於解釋檢索結果
+	Keyword      float64  `json:"keyword"`
+	Semantic     float64  `json:"semantic"`
+	Time         float64  `json:"time"`
+	MatchedTerms []string `json:"matched_terms"`
+	Strength     float64  `json:"strength"`
+	// 未達門檻，僅因結果不足 5 筆而補上
+	Padded bool `json:"padded"`
+	// 依 Recall 設定一併注入的記錄，依時間排序並包含 Record 本身
+	Group []*ConversationRecord `json:"-"`
 }
 
 type Comparer struct {
+	mu         sync.RWMutex
 	recordList []*ConversationRecord
-	threshold  float64
+	config     ScoringConfig
+	embedder   Embedder
+	// 以記錄 ID 為鍵的向量索引，設定 Embedder 時建立
+	vectorIndex *VectorIndex
+	// 倒排索引：關鍵詞 → 記錄位置 → 詞頻
+	index       map[string]map[int]int
+	totalLength int
+	turnID      int
 }
 
-func NewFuzzyComparer(threshold float64) *Comparer {
-	return &Comparer{
+// BM25 參數
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// 向量索引取回的候選數量，與關鍵詞候選合併後再計算完整分數
+const vectorCandidateCount = 50
+
+// 還原時每次送出計算向量的記錄數
+const embedBatchSize = 64
+
+// 參與 MMR 重排的候選上限，其餘維持分數順序接在後面
+const mmrCandidateCount = 50
+
+// 檢索報告保留的候選數，其中至多 reportBelowCount 筆為門檻以下
+const (
+	reportCandidateCount = 50
+	reportBelowCount     = 10
+)
+
+// 計算查詢向量的等待上限，逾時退回詞彙重疊，不拖慢回覆
+const queryEmbedTimeout = 5 * time.Second
+
+func NewFuzzyComparer(threshold float64, options ...ComparerOption) *Comparer {
+	config := DefaultScoringConfig()
+	config.Threshold = threshold
+
+	comparer := &Comparer{
 		recordList: make([]*ConversationRecord, 0),
-		threshold:  threshold,
+		config:     config,
+		index:      make(map[string]map[int]int),
+	}
+	for _, option := range options {
+		option(comparer)
 	}
+	return comparer
 }
 
 func (f *Comparer) AddRecord(speaker, content string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// 使用者提問開始新回合，回覆歸入目前回合
+	if speaker != "assistant" || f.turnID == 0 {
+		f.turnID++
+	}
+
 	record := &ConversationRecord{
-		ID:      len(f.recordList) + 1,
-		SendAt:  time.Now(),
-		User:    speaker,
-		Content: content,
-		Keyword: getKeywordList(content),
+		ID:       len(f.recordList) + 1,
+		SendAt:   time.Now(),
+		User:     speaker,
+		Content:  content,
+		Keyword:  getKeywordList(content),
+		TurnID:   f.turnID,
+		Strength: 1.0,
 	}
 	f.recordList = append(f.recordList, record)
+	f.indexRecord(len(f.recordList)-1, record)
+
+	// 向量可能需要網路請求，背景計算避免卡住 UI
+	if f.embedder != nil {
+		go f.embedRecord(f.embedder, f.vectorIndex, record)
+	}
 }
 
-func (f *Comparer) Search(query string) []*ConversationRecord {
-	if len(f.recordList) == 0 {
+// 目前記錄數，最後一筆的 ID 即為此值
+func (f *Comparer) Len() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return len(f.recordList)
+}
+
+func (f *Comparer) embedRecord(embedder Embedder, index *VectorIndex, record *ConversationRecord) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	vectorList, err := embedder.Embed(ctx, []string{record.Content})
+	if err != nil || len(vectorList) == 0 {
+		return
+	}
+
+	f.mu.Lock()
+	record.vector = vectorList[0]
+	f.mu.Unlock()
+	index.Insert(record.ID, vectorList[0])
+}
+
+// 向量索引與對話記錄存放在一起，恢復時不需重新計算向量
+func (f *Comparer) SaveVectorIndex(path string) error {
+	f.mu.RLock()
+	index := f.vectorIndex
+	f.mu.RUnlock()
+
+	if index == nil {
 		return nil
 	}
+	return index.Save(path)
+}
+
+func (f *Comparer) LoadVectorIndex(path string) error {
+	index, err := LoadVectorIndex(path)
+	if err != nil {
+		return err
+	}
 
-	keywordList := getKeywordList(query)
-	resultList := make([]SearchResult, 0)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.vectorIndex = index
+	for _, record := range f.recordList {
+		record.vector = index.Vector(record.ID)
+	}
+	return nil
+}
 
-	// 對每個歷史記錄計算相關性分數
+// 複製目前的記錄供保存，避免寫入時與強化或向量計算同時修改
+func (f *Comparer) Records() []*ConversationRecord {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	recordList := make([]*ConversationRecord, 0, len(f.recordList))
 	for _, record := range f.recordList {
-		score := f.calcScore(keywordList, record, query)
-		if score >= f.threshold {
-			resultList = append(resultList, SearchResult{
-				Record: record,
-				Score:  score,
-			})
+		copied := *record
+		recordList = append(recordList, &copied)
+	}
+	return recordList
+}
+
+// 以保存的記錄取代目前內容並重建索引，保留原本的 SendAt 讓時間衰減正確
+// 向量需另外由 LoadVectorIndex 或 EmbedMissing 補上
+func (f *Comparer) Restore(recordList []*ConversationRecord) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.recordList = make([]*ConversationRecord, 0, len(recordList))
+	f.index = make(map[string]map[int]int)
+	f.totalLength = 0
+	f.turnID = 0
+	if f.embedder != nil {
+		f.vectorIndex = NewVectorIndex()
+	}
+
+	for _, record := range recordList {
+		// 位置即 ID - 1，搜尋與分組都依賴此對應
+		record.ID = len(f.recordList) + 1
+		record.vector = nil
+		if record.Strength <= 0 {
+			record.Strength = 1.0
 		}
+		f.recordList = append(f.recordList, record)
+		f.indexRecord(len(f.recordList)-1, record)
+		f.turnID = max(f.turnID, record.TurnID)
 	}
+}
 
-	// 按分數排序
-	sort.Slice(resultList, func(i, j int) bool {
-		return resultList[i].Score > resultList[j].Score
-	})
+// 背景分批計算尚無向量的記錄，用於還原後補齊向量索引中缺少的記錄
+func (f *Comparer) EmbedMissing() {
+	f.mu.RLock()
+	embedder := f.embedder
+	index := f.vectorIndex
+	missingList := make([]*ConversationRecord, 0)
+	for _, record := range f.recordList {
+		if record.vector == nil {
+			missingList = append(missingList, record)
+		}
+	}
+	f.mu.RUnlock()
 
-	// 相關記錄
-	relevantRecords := make([]*ConversationRecord, 0, len(resultList))
-	for _, result := range resultList {
-		relevantRecords = append(relevantRecords, result.Record)
+	if embedder == nil || len(missingList) == 0 {
+		return
 	}
 
-	if len(relevantRecords) < 5 {
-		for _, record := range f.recordList {
-			exists := false
-			for _, existing := range relevantRecords {
-				if existing.Content == record.Content {
-					exists = true
-					break
-				}
+	go func() {
+		for start := 0; start < len(missingList); start += embedBatchSize {
+			batch := missingList[start:min(start+embedBatchSize, len(missingList))]
+			contentList := make([]string, 0, len(batch))
+			for _, record := range batch {
+				contentList = append(contentList, record.Content)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			vectorList, err := embedder.Embed(ctx, contentList)
+			cancel()
+			if err != nil || len(vectorList) != len(batch) {
+				return
+			}
+
+			for i, record := range batch {
+				f.mu.Lock()
+				record.vector = vectorList[i]
+				f.mu.Unlock()
+				index.Insert(record.ID, vectorList[i])
 			}
+		}
+	}()
+}
+
+func (r *ConversationRecord) buildWordSet() {
+	wordList := tokenize(r.Content)
+	r.wordSet = make(map[string]bool, len(wordList))
+	for _, word := range wordList {
+		r.wordSet[word] = true
+	}
+	r.wordCount = len(r.wordSet)
+}
+
+// 新增記錄時同步更新倒排索引，搜尋時不需再掃描全部記錄
+func (f *Comparer) indexRecord(pos int, record *ConversationRecord) {
+	record.buildWordSet()
+	for _, keyword := range record.Keyword {
+		postings, ok := f.index[keyword]
+		if !ok {
+			postings = make(map[int]int)
+			f.index[keyword] = postings
+		}
+		postings[pos]++
+	}
+	f.totalLength += len(record.Keyword)
+}
 
-			if !exists {
-				relevantRecords = append(relevantRecords, record)
-				if len(relevantRecords) >= 5 {
+func (f *Comparer) Search(ctx context.Context, query string) []SearchResult {
+	return f.Explain(ctx, query, 0).Results
+}
+
+// 搜尋並保留所有候選的分數明細，供除錯面板顯示
+// excludeID 為本輪提問本身的記錄，不作為候選，以免影響 MMR 的相似度懲罰
+func (f *Comparer) Explain(ctx context.Context, query string, excludeID int) SearchReport {
+	f.mu.RLock()
+	embedder := f.embedder
+	vectorIndex := f.vectorIndex
+	f.mu.RUnlock()
+
+	// 查詢向量失敗時退回詞彙重疊，不影響搜尋
+	var queryVector []float32
+	if embedder != nil {
+		embedCtx, cancel := context.WithTimeout(ctx, queryEmbedTimeout)
+		if vectorList, err := embedder.Embed(embedCtx, []string{query}); err == nil && len(vectorList) > 0 {
+			queryVector = vectorList[0]
+		}
+		cancel()
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	report := SearchReport{
+		Query:     query,
+		Threshold: f.config.Threshold,
+		MMRLambda: f.config.MMRLambda,
+	}
+	if len(f.recordList) == 0 {
+		return report
+	}
+
+	keywordList := getKeywordList(query)
+	queryWordList := uniqueWords(tokenize(query))
+	keywordScoreList := f.calcBM25(keywordList)
+
+	// 只對命中倒排索引的記錄計算分數
+	// 有向量時同義但不同詞的記錄也可能相關，再由向量索引補上最接近的候選
+	candidateList := make(map[int]bool, len(keywordScoreList)+vectorCandidateCount)
+	for pos := range keywordScoreList {
+		candidateList[pos] = true
+	}
+	if queryVector != nil && vectorIndex != nil {
+		for _, match := range vectorIndex.Search(queryVector, vectorCandidateCount) {
+			// 記錄 ID 從 1 開始
+			candidateList[match.ID-1] = true
+		}
+	}
+
+	for pos := range candidateList {
+		if pos < 0 || pos >= len(f.recordList) || pos == excludeID-1 {
+			continue
+		}
+		result := f.calcScore(keywordScoreList[pos], keywordList, queryWordList, queryVector, f.recordList[pos])
+		report.Candidates = append(report.Candidates, result)
+	}
+
+	// 按分數排序，同分時較新的優先以保持結果穩定
+	sort.Slice(report.Candidates, func(i, j int) bool {
+		if report.Candidates[i].Score == report.Candidates[j].Score {
+			return report.Candidates[i].Record.ID > report.Candidates[j].Record.ID
+		}
+		return report.Candidates[i].Score > report.Candidates[j].Score
+	})
+
+	// 同一回合只保留分數最高的記錄作為代表
+	resultList := make([]SearchResult, 0)
+	existsList := make(map[int]bool)
+	for _, result := range report.Candidates {
+		if result.Score >= f.config.Threshold && !existsList[f.groupKey(result.Record)] {
+			existsList[f.groupKey(result.Record)] = true
+			resultList = append(resultList, result)
+		}
+	}
+
+	// 重複敘述同一件事的記錄容易佔滿結果，以 MMR 分散主題
+	resultList = f.rerankMMR(resultList, queryVector != nil)
+
+	// 相關記錄不足時依時間順序補齊
+	if len(resultList) < 5 {
+		for pos, record := range f.recordList {
+			if record.ID != excludeID && !existsList[f.groupKey(record)] {
+				existsList[f.groupKey(record)] = true
+				result := f.calcScore(keywordScoreList[pos], keywordList, queryWordList, queryVector, record)
+				result.Padded = true
+				resultList = append(resultList, result)
+				if len(resultList) >= 5 {
 					break
 				}
 			}
 		}
 	}
 
-	return relevantRecords
+	for i := range resultList {
+		resultList[i].Group = f.group(resultList[i].Record)
+	}
+
+	report.Results = resultList
+	report.CandidateCount = len(report.Candidates)
+	report.Candidates = trimCandidates(report.Candidates, f.config.Threshold)
+	return report
 }
 
-func (f *Comparer) calcScore(keywordList []string, record *ConversationRecord, query string) float64 {
-	keyword := f.calcKeyword(keywordList, record.Keyword)
-	semantic := f.calcSemantic(query, record.Content)
-	time := f.calcTime(record.SendAt)
+// 只保留門檻附近的候選：門檻以上分數最高的幾筆與緊接在門檻下的幾筆
+// 關鍵詞常見時候選可能上千筆，全部保留會讓報告與除錯面板過大
+func trimCandidates(candidateList []SearchResult, threshold float64) []SearchResult {
+	if len(candidateList) <= reportCandidateCount {
+		return candidateList
+	}
+
+	above := sort.Search(len(candidateList), func(i int) bool {
+		return candidateList[i].Score < threshold
+	})
+	below := min(len(candidateList)-above, reportBelowCount)
+	keepAbove := min(above, reportCandidateCount-below)
+	keepBelow := min(len(candidateList)-above, reportCandidateCount-keepAbove)
 
-	return keyword*0.4 + semantic*0.4 + time*0.2
+	trimmedList := make([]SearchResult, 0, keepAbove+keepBelow)
+	trimmedList = append(trimmedList, candidateList[:keepAbove]...)
+	return append(trimmedList, candidateList[above:above+keepBelow]...)
 }
 
-// 計算關鍵詞重疊
-func (f *Comparer) calcKeyword(queryKeywordList, recordKeywordList []string) float64 {
-	if len(queryKeywordList) == 0 || len(recordKeywordList) == 0 {
-		return 0.0
+// Maximal Marginal Relevance：每次挑選 λ·分數 − (1−λ)·與已選結果最大相似度 最高者
+func (f *Comparer) rerankMMR(resultList []SearchResult, useVector bool) []SearchResult {
+	lambda := f.config.MMRLambda
+	if lambda >= 1 || len(resultList) <= 2 {
+		return resultList
 	}
 
-	matches := 0
-	for _, qk := range queryKeywordList {
-		for _, rk := range recordKeywordList {
-			if strings.Contains(strings.ToLower(qk), strings.ToLower(rk)) ||
-				strings.Contains(strings.ToLower(rk), strings.ToLower(qk)) {
-				matches++
-				break
+	count := min(len(resultList), mmrCandidateCount)
+	remaining := make([]SearchResult, count)
+	copy(remaining, resultList[:count])
+	selected := make([]SearchResult, 0, len(resultList))
+
+	for len(remaining) > 0 {
+		best, bestValue := 0, math.Inf(-1)
+		for i, candidate := range remaining {
+			maxSimilarity := 0.0
+			for _, chosen := range selected {
+				maxSimilarity = math.Max(maxSimilarity, f.recordSimilarity(candidate.Record, chosen.Record, useVector))
+			}
+
+			value := lambda*candidate.Score - (1-lambda)*maxSimilarity
+			if value > bestValue {
+				best, bestValue = i, value
 			}
 		}
-	}
 
-	union := len(queryKeywordList) + len(recordKeywordList) - matches
-	if union == 0 {
-		return 0.0
+		selected = append(selected, remaining[best])
+		remaining = append(remaining[:best], remaining[best+1:]...)
 	}
 
-	return float64(matches) / float64(union)
+	return append(selected, resultList[count:]...)
 }
 
-// 計算語義相似度
-func (f *Comparer) calcSemantic(query, content string) float64 {
-	queryWordList := strings.Fields(strings.ToLower(query))
-	contentWordList := strings.Fields(strings.ToLower(content))
+// 記錄間的相似度：兩者皆有向量時使用餘弦相似度，否則使用詞彙集合的 Jaccard 係數
+func (f *Comparer) recordSimilarity(a, b *ConversationRecord, useVector bool) float64 {
+	if useVector && a.vector != nil && b.vector != nil {
+		return math.Max(cosineSimilarity(a.vector, b.vector), 0.0)
+	}
 
-	if len(queryWordList) == 0 || len(contentWordList) == 0 {
+	if a.wordCount == 0 || b.wordCount == 0 {
 		return 0.0
 	}
 
-	// 計算共同詞彙比例
 	count := 0
-	for _, qw := range queryWordList {
-		for _, cw := range contentWordList {
-			if qw == cw {
-				count++
-				break
-			}
+	for word := range a.wordSet {
+		if b.wordSet[word] {
+			count++
 		}
 	}
+	return float64(count) / float64(len(a.wordSet)+len(b.wordSet)-count)
+}
 
-	// 使用餘弦相似度概念
-	return float64(count) / math.Sqrt(float64(len(queryWordList)*len(contentWordList)))
+// 回合模式下同一回合視為同一筆結果
+func (f *Comparer) groupKey(record *ConversationRecord) int {
+	if f.config.Recall == RecallTurn {
+		return record.TurnID
+	}
+	return record.ID
+}
+
+// 依 Recall 設定取得命中記錄需一併注入的記錄，回合與視窗皆為連續區間
+func (f *Comparer) group(record *ConversationRecord) []*ConversationRecord {
+	pos := record.ID - 1
+	start, end := pos, pos+1
+
+	switch f.config.Recall {
+	case RecallTurn:
+		for start > 0 && f.recordList[start-1].TurnID == record.TurnID {
+			start--
+		}
+		for end < len(f.recordList) && f.recordList[end].TurnID == record.TurnID {
+			end++
+		}
+	case RecallWindow:
+		start = max(pos-f.config.RecallWindow, 0)
+		end = min(pos+f.config.RecallWindow+1, len(f.recordList))
+	}
+
+	return f.recordList[start:end]
 }
 
-// 計算時間衰減分數
-func (f *Comparer) calcTime(timestamp time.Time) float64 {
+// 被注入提示的記錄依間隔重複（spaced repetition）強化：
+// 越接近遺忘時被想起，強度提升越多；連續每輪都被想起則提升有限，但衰減起點會重設
+// 以記錄 ID 指定，回合或視窗中一併注入的記錄同樣算被想起
+func (f *Comparer) Reinforce(idList []int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
 	now := time.Now()
-	duration := now.Sub(timestamp)
-	hours := duration.Hours()
+	for _, id := range idList {
+		if id < 1 || id > len(f.recordList) {
+			continue
+		}
 
-	// 24小時內線性衰減：最近=1.0，24小時前=0.7
-	// 超過24小時後維持固定分數0.7
-	if hours <= 24 {
-		return 1.0 - (hours * 0.3 / 24.0)
+		record := f.recordList[id-1]
+		retention := f.config.timeScore(f.elapsed(record, now))
+		record.Strength *= 1 + f.config.ReinforceGain*(1-retention)
+		record.RecallCount++
+		record.LastRecalledAt = now
+	}
+}
+
+// 以最後一次想起為起點，並依強度延長有效時間
+func (f *Comparer) elapsed(record *ConversationRecord, now time.Time) time.Duration {
+	start := record.SendAt
+	if record.LastRecalledAt.After(start) {
+		start = record.LastRecalledAt
 	}
 
-	// 超過24小時後固定分數
-	return 0.7
+	strength := record.Strength
+	if strength < 1 {
+		strength = 1
+	}
+	return time.Duration(float64(now.Sub(start)) / strength)
 }
 
-// TODO: 需研究提取關鍵詞的寫法
-// 提取關鍵詞
-func getKeywordList(text string) []string {
-	// 停用詞列表
-	stopList := map[string]bool{
-		"的": true, "是": true, "在": true, "有": true, "和": true,
-		"與": true, "或": true, "但": true, "這": true, "那": true,
-		"我": true, "你": true, "他": true, "她": true, "它": true,
-		"了": true, "嗎": true, "呢": true, "啊": true, "吧": true,
-		"the": true, "is": true, "at": true, "which": true, "on": true,
-		"and": true, "or": true, "but": true, "this": true, "that": true,
-		"i": true, "you": true, "he": true, "she": true, "it": true,
-	}
-
-	wordList := strings.Fields(strings.ToLower(text))
-	keywordList := make([]string, 0)
+func (f *Comparer) calcScore(keyword float64, queryKeywordList, queryWordList []string, queryVector []float32, record *ConversationRecord) SearchResult {
+	semantic := f.calcSemantic(queryWordList, queryVector, record)
+	time := f.calcTime(record)
 
-	for _, word := range wordList {
-		// 移除標點符號
-		word = strings.Trim(word, ".,!?;:()[]{}\"'")
+	// 同時出現在查詢與記錄中的關鍵詞
+	matchedTerms := make([]string, 0)
+	seen := make(map[string]bool, len(queryKeywordList))
+	for _, term := range queryKeywordList {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+		if f.index[term][record.ID-1] > 0 {
+			matchedTerms = append(matchedTerms, term)
+		}
+	}
 
-		// 過濾停用詞和短詞
-		if len(word) >= 2 && !stopList[word] {
-			keywordList = append(keywordList, word)
+	return SearchResult{
+		Record:       record,
+		Score:        keyword*f.config.KeywordWeight + semantic*f.config.SemanticWeight + time*f.config.TimeWeight,
+		Keyword:      keyword,
+		Semantic:     semantic,
+		Time:         time,
+		MatchedTerms: matchedTerms,
+		Strength:     record.Strength,
+	}
+}
+
+// BM25 關鍵詞分數，IDF 降低常見詞的權重，並依記錄長度正規化
+// 以「每個查詢詞在平均長度記錄中出現一次」的分數為 1.0，結果上限為 1.0
+func (f *Comparer) calcBM25(queryKeywordList []string) map[int]float64 {
+	scoreList := make(map[int]float64)
+	if len(queryKeywordList) == 0 || f.totalLength == 0 {
+		return scoreList
+	}
+
+	total := float64(len(f.recordList))
+	avgLength := float64(f.totalLength) / total
+
+	maxScore := 0.0
+	seen := make(map[string]bool, len(queryKeywordList))
+	for _, keyword := range queryKeywordList {
+		if seen[keyword] {
+			continue
+		}
+		seen[keyword] = true
+
+		postings := f.index[keyword]
+		df := float64(len(postings))
+		idf := math.Log(1 + (total-df+0.5)/(df+0.5))
+		maxScore += idf
+
+		for pos, tf := range postings {
+			length := float64(len(f.recordList[pos].Keyword))
+			norm := float64(tf) * (bm25K1 + 1) / (float64(tf) + bm25K1*(1-bm25B+bm25B*length/avgLength))
+			scoreList[pos] += idf * norm
 		}
 	}
 
-	return keywordList
+	for pos, score := range scoreList {
+		scoreList[pos] = math.Min(score/maxScore, 1.0)
+	}
+	return scoreList
 }
 
-// 格式化相關上下文
-func (f *Comparer) FormatRelevant(records []*ConversationRecord) string {
-	if len(records) == 0 {
-		return ""
+// 計算語義相似度：有向量時使用餘弦相似度，否則退回詞彙重疊比例
+func (f *Comparer) calcSemantic(queryWordList []string, queryVector []float32, record *ConversationRecord) float64 {
+	if queryVector != nil && record.vector != nil {
+		return math.Max(cosineSimilarity(queryVector, record.vector), 0.0)
 	}
 
-	var builder strings.Builder
-	builder.WriteString("=== 相關歷史對話 ===\n")
+	if len(queryWordList) == 0 || record.wordCount == 0 {
+		return 0.0
+	}
 
-	if len(records) > 0 {
-		for i, record := range records {
-			if i == 0 {
-				continue // 跳過第一條記錄
-			}
-			if i >= 5 {
-				break
-			}
+	// 計算共同詞彙比例，查詢與記錄皆以不重複詞計算，結果不超過 1
+	count := 0
+	for _, qw := range queryWordList {
+		if record.wordSet[qw] {
+			count++
+		}
+	}
 
-			speakerName := "User"
-			if record.User == "assistant" {
-				speakerName = "LLM"
-			}
+	return float64(count) / math.Sqrt(float64(len(queryWordList)*record.wordCount))
+}
 
-			builder.WriteString(fmt.Sprintf("%s: %s\n", speakerName, record.Content))
+// 計算時間衰減分數，衰減曲線由 ScoringConfig 決定，記憶強度越高衰減越慢
+func (f *Comparer) calcTime(record *ConversationRecord) float64 {
+	return f.config.timeScore(f.elapsed(record, time.Now()))
+}
+
+// 去除重複詞並保留原本順序
+func uniqueWords(wordList []string) []string {
+	seen := make(map[string]bool, len(wordList))
+	uniqueList := make([]string, 0, len(wordList))
+	for _, word := range wordList {
+		if !seen[word] {
+			seen[word] = true
+			uniqueList = append(uniqueList, word)
 		}
-		builder.WriteString("\n")
 	}
+	return uniqueList
+}
 
-	return builder.String()
+// 提取關鍵詞
+func getKeywordList(text string) []string {
+	keywordList := make([]string, 0)
+
+	for _, word := range tokenize(text) {
+		// 過濾停用詞和短詞（單一中文字仍保留）
+		if len(word) >= 2 && !stopList[word] {
+			keywordList = append(keywordList, word)
+		}
+	}
+
+	return keywordList
 }
@@ -0,0 +1,294 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 以固定亂數種子產生的合成語料，中英混合並讓詞頻呈長尾分布
+func syntheticCorpus(count int) []string {
+	rng := rand.New(rand.NewSource(1))
+
+	vocabulary := make([]string, 0, 3000)
+	for i := 0; i < 2500; i++ {
+		vocabulary = append(vocabulary, fmt.Sprintf("term%d", i))
+	}
+	for _, word := range []string{"預算", "部署", "資料庫", "快取", "延遲", "索引", "向量", "會議", "報告", "排程"} {
+		vocabulary = append(vocabulary, word)
+	}
+
+	contentList := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		length := 8 + rng.Intn(40)
+		wordList := make([]string, 0, length)
+		for j := 0; j < length; j++ {
+			// 平方讓前面的詞較常出現
+			index := int(math.Pow(rng.Float64(), 2) * float64(len(vocabulary)))
+			wordList = append(wordList, vocabulary[index])
+		}
+		contentList = append(contentList, strings.Join(wordList, " "))
+	}
+	return contentList
+}
+
+var benchmarkQueryList = []string{
+	"term3 term15 term120 預算",
+	"資料庫 索引 term42",
+	"term7 term900 term1800 快取 延遲",
+	"how should term2 handle 部署 排程",
+}
+
+func newBenchmarkComparer(contentList []string) *Comparer {
+	comparer := NewFuzzyComparer(0.3)
+	for i, content := range contentList {
+		speaker := "user"
+		if i%2 == 1 {
+			speaker = "assistant"
+		}
+		comparer.AddRecord(speaker, content)
+	}
+	return comparer
+}
+
+func BenchmarkSearchBM25(b *testing.B) {
+	for _, size := range []int{10000, 50000} {
+		comparer := newBenchmarkComparer(syntheticCorpus(size))
+		b.Run(fmt.Sprintf("records=%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				comparer.Search(context.Background(), benchmarkQueryList[i%len(benchmarkQueryList)])
+			}
+		})
+	}
+}
+
+func BenchmarkSearchLinear(b *testing.B) {
+	for _, size := range []int{10000, 50000} {
+		recordList := newLinearRecordList(syntheticCorpus(size))
+		b.Run(fmt.Sprintf("records=%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				linearSearch(recordList, benchmarkQueryList[i%len(benchmarkQueryList)], 0.3)
+			}
+		})
+	}
+}
+
+func TestSearchBM25(t *testing.T) {
+	comparer := NewFuzzyComparer(0.3)
+	comparer.AddRecord("user", "我們的資料庫需要建立索引")
+	comparer.AddRecord("assistant", "可以針對查詢欄位建立 btree 索引")
+	comparer.AddRecord("user", "下週的會議改到星期三")
+	comparer.AddRecord("assistant", "好的，會議改到星期三下午")
+	comparer.AddRecord("user", "預算上限是十萬元")
+	comparer.AddRecord("assistant", "了解，預算上限十萬元")
+
+	resultList := comparer.Search(context.Background(), "資料庫索引怎麼建立")
+	if len(resultList) == 0 {
+		t.Fatal("no results")
+	}
+	if resultList[0].Record.TurnID != 1 || resultList[0].Padded {
+		t.Errorf("top result = #%d (turn %d, padded %v), want a turn 1 record",
+			resultList[0].Record.ID, resultList[0].Record.TurnID, resultList[0].Padded)
+	}
+	if resultList[0].Keyword <= 0 || len(resultList[0].MatchedTerms) == 0 {
+		t.Errorf("keyword = %.3f, terms = %v, want BM25 matches", resultList[0].Keyword, resultList[0].MatchedTerms)
+	}
+}
+
+// 重複的查詢詞不可讓分數超過 0..1 的範圍
+func TestSearchRepeatedQueryWords(t *testing.T) {
+	comparer := NewFuzzyComparer(0.3)
+	comparer.AddRecord("user", "database")
+	comparer.AddRecord("assistant", "database database index")
+
+	report := comparer.Explain(context.Background(), "database database database", 0)
+	if len(report.Candidates) != 2 {
+		t.Fatalf("candidates = %d, want 2", len(report.Candidates))
+	}
+	for _, result := range report.Candidates {
+		if result.Semantic > 1 || result.Keyword > 1 || result.Score > 1 {
+			t.Errorf("#%d score = %.3f (K %.3f, S %.3f), want within 0..1", result.Record.ID, result.Score, result.Keyword, result.Semantic)
+		}
+		if result.Record.ID == 1 && result.Semantic < 0.999 {
+			t.Errorf("#1 semantic = %.3f, want 1 for identical words", result.Semantic)
+		}
+	}
+}
+
+// 回合中一併注入的記錄也算被想起，未注入的不受影響
+func TestReinforceInjectedIDs(t *testing.T) {
+	comparer := NewFuzzyComparer(0.3)
+	comparer.AddRecord("user", "預算上限是多少")
+	comparer.AddRecord("assistant", "預算上限是十萬元")
+	comparer.AddRecord("user", "下週的會議改到星期三")
+
+	comparer.Reinforce([]int{1, 2, 99})
+
+	recordList := comparer.Records()
+	for i, want := range []int{1, 1, 0} {
+		if recordList[i].RecallCount != want {
+			t.Errorf("#%d recall count = %d, want %d", recordList[i].ID, recordList[i].RecallCount, want)
+		}
+	}
+	if recordList[1].LastRecalledAt.IsZero() || !recordList[2].LastRecalledAt.IsZero() {
+		t.Errorf("last recalled = %v, %v", recordList[1].LastRecalledAt, recordList[2].LastRecalledAt)
+	}
+}
+
+func TestTrimCandidates(t *testing.T) {
+	// 分數由高到低，前 above 筆在門檻 0.5 以上
+	candidates := func(above, below int) []SearchResult {
+		resultList := make([]SearchResult, 0, above+below)
+		for i := 0; i < above+below; i++ {
+			score := 0.9
+			if i >= above {
+				score = 0.1
+			}
+			resultList = append(resultList, SearchResult{Record: &ConversationRecord{ID: i + 1}, Score: score})
+		}
+		return resultList
+	}
+
+	for _, test := range []struct {
+		above, below         int
+		wantAbove, wantBelow int
+	}{
+		{10, 20, 10, 20},
+		{10, 1000, 10, 40},
+		{1000, 1000, 40, 10},
+		{1000, 3, 47, 3},
+	} {
+		trimmedList := trimCandidates(candidates(test.above, test.below), 0.5)
+		gotAbove, gotBelow := 0, 0
+		for _, result := range trimmedList {
+			if result.Score >= 0.5 {
+				gotAbove++
+			} else {
+				gotBelow++
+			}
+		}
+		if gotAbove != test.wantAbove || gotBelow != test.wantBelow {
+			t.Errorf("trim(%d, %d) = %d above, %d below, want %d, %d",
+				test.above, test.below, gotAbove, gotBelow, test.wantAbove, test.wantBelow)
+		}
+		// 門檻以下保留的是最接近門檻的幾筆
+		if gotBelow > 0 && trimmedList[gotAbove].Record.ID != test.above+1 {
+			t.Errorf("trim(%d, %d) first below = #%d, want #%d", test.above, test.below, trimmedList[gotAbove].Record.ID, test.above+1)
+		}
+	}
+}
+
+// 以下為改用 BM25 前的線性掃描評分，保留作為效能比較基準
+
+type linearRecord struct {
+	SendAt  time.Time
+	Content string
+	Keyword []string
+}
+
+func newLinearRecordList(contentList []string) []*linearRecord {
+	recordList := make([]*linearRecord, 0, len(contentList))
+	for _, content := range contentList {
+		recordList = append(recordList, &linearRecord{
+			SendAt:  time.Now(),
+			Content: content,
+			Keyword: linearKeywordList(content),
+		})
+	}
+	return recordList
+}
+
+func linearSearch(recordList []*linearRecord, query string, threshold float64) []*linearRecord {
+	keywordList := linearKeywordList(query)
+
+	type linearResult struct {
+		record *linearRecord
+		score  float64
+	}
+	resultList := make([]linearResult, 0)
+	for _, record := range recordList {
+		score := linearKeyword(keywordList, record.Keyword)*0.4 + linearSemantic(query, record.Content)*0.4 + linearTime(record.SendAt)*0.2
+		if score >= threshold {
+			resultList = append(resultList, linearResult{record, score})
+		}
+	}
+
+	sort.Slice(resultList, func(i, j int) bool {
+		return resultList[i].score > resultList[j].score
+	})
+
+	relevantList := make([]*linearRecord, 0, len(resultList))
+	for _, result := range resultList {
+		relevantList = append(relevantList, result.record)
+	}
+	return relevantList
+}
+
+// 關鍵詞重疊的 Jaccard 係數，子字串也視為命中
+func linearKeyword(queryKeywordList, recordKeywordList []string) float64 {
+	if len(queryKeywordList) == 0 || len(recordKeywordList) == 0 {
+		return 0.0
+	}
+
+	matches := 0
+	for _, qk := range queryKeywordList {
+		for _, rk := range recordKeywordList {
+			if strings.Contains(strings.ToLower(qk), strings.ToLower(rk)) ||
+				strings.Contains(strings.ToLower(rk), strings.ToLower(qk)) {
+				matches++
+				break
+			}
+		}
+	}
+
+	union := len(queryKeywordList) + len(recordKeywordList) - matches
+	if union == 0 {
+		return 0.0
+	}
+	return float64(matches) / float64(union)
+}
+
+func linearSemantic(query, content string) float64 {
+	queryWordList := strings.Fields(strings.ToLower(query))
+	contentWordList := strings.Fields(strings.ToLower(content))
+	if len(queryWordList) == 0 || len(contentWordList) == 0 {
+		return 0.0
+	}
+
+	count := 0
+	for _, qw := range queryWordList {
+		for _, cw := range contentWordList {
+			if qw == cw {
+				count++
+				break
+			}
+		}
+	}
+	return float64(count) / math.Sqrt(float64(len(queryWordList)*len(contentWordList)))
+}
+
+func linearTime(timestamp time.Time) float64 {
+	hours := time.Since(timestamp).Hours()
+	if hours <= 24 {
+		return 1.0 - (hours * 0.3 / 24.0)
+	}
+	return 0.7
+}
+
+func linearKeywordList(text string) []string {
+	keywordList := make([]string, 0)
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		word = strings.Trim(word, ".,!?;:()[]{}\"'")
+		if len(word) >= 2 && !stopList[word] {
+			keywordList = append(keywordList, word)
+		}
+	}
+	return keywordList
+}
@@ -0,0 +1,158 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+)
+
+// 預設用於相關歷史對話的 token 上限
+const DefaultMemoryTokenBudget = 2000
+
+// 預留給模型回覆的 token 數，計算可用上下文時扣除
+const responseTokenReserve = 4096
+
+// 剩餘空間少於此數時不再截斷塞入，直接捨棄
+const minTruncateTokens = 32
+
+const truncateMarker = "…（已截斷）"
+
+var (
+	encodingMu   sync.Mutex
+	encodingList = make(map[string]*tiktoken.Tiktoken)
+)
+
+// 依模型選擇 tiktoken 編碼並快取，非 OpenAI 模型以 o200k_base 近似
+func encodingForModel(model string) (*tiktoken.Tiktoken, error) {
+	encodingMu.Lock()
+	defer encodingMu.Unlock()
+
+	if encoding, ok := encodingList[model]; ok {
+		return encoding, nil
+	}
+
+	encoding, err := tiktoken.EncodingForModel(model)
+	if err != nil {
+		encoding, err = tiktoken.GetEncoding("o200k_base")
+		if err != nil {
+			return nil, err
+		}
+	}
+	encodingList[model] = encoding
+	return encoding, nil
+}
+
+// 依 token 預算組合相關歷史對話
+type ContextBuilder struct {
+	Encoding *tiktoken.Tiktoken
+	Budget   int
+}
+
+type ContextResult struct {
+	Content  string         `json:"content"`
+	Tokens   int            `json:"tokens"`
+	Budget   int            `json:"budget"`
+	Included []SearchResult `json:"included"`
+	// 實際注入提示的所有記錄 ID，包含回合或視窗中的其他記錄
+	InjectedIDs []int `json:"injected_ids"`
+	// 因相關而注入的記錄 ID，不含僅為補齊結果而放入的記錄，用於強化
+	RecalledIDs []int `json:"recalled_ids"`
+	// 被截斷的記錄 ID
+	Truncated []int          `json:"truncated"`
+	Dropped   []SearchResult `json:"dropped"`
+}
+
+// 依分數順序貪婪填入預算，放不下的記錄在剩餘空間足夠時截斷，否則捨棄
+// excludeID 為本輪提問本身的記錄，不重複放入
+func (b *ContextBuilder) Build(resultList []SearchResult, excludeID int) ContextResult {
+	result := ContextResult{
+		Budget: b.Budget,
+	}
+
+	header := "=== 相關歷史對話 ===\n"
+	remaining := b.Budget - b.count(header)
+
+	// 回合或視窗可能重疊，已放入的記錄不重複
+	injectedList := map[int]bool{excludeID: true}
+
+	var builder strings.Builder
+	for _, item := range resultList {
+		group := item.Group
+		if group == nil {
+			group = []*ConversationRecord{item.Record}
+		}
+
+		var block strings.Builder
+		idList := make([]int, 0, len(group))
+		for _, record := range group {
+			if injectedList[record.ID] {
+				continue
+			}
+
+			speakerName := "User"
+			if record.User == "assistant" {
+				speakerName = "LLM"
+			}
+			block.WriteString(fmt.Sprintf("%s: %s\n", speakerName, record.Content))
+			idList = append(idList, record.ID)
+		}
+		if len(idList) == 0 {
+			continue
+		}
+
+		// 多筆記錄組成的區塊之間以空行分隔
+		text := block.String()
+		if len(group) > 1 {
+			text += "\n"
+		}
+
+		tokens := b.count(text)
+		if tokens > remaining {
+			if remaining < minTruncateTokens {
+				result.Dropped = append(result.Dropped, item)
+				continue
+			}
+
+			text = strings.TrimRight(b.truncate(text, remaining-b.count(truncateMarker+"\n")), "\n") + truncateMarker + "\n"
+			tokens = b.count(text)
+			result.Truncated = append(result.Truncated, item.Record.ID)
+		}
+
+		builder.WriteString(text)
+		remaining -= tokens
+		result.Included = append(result.Included, item)
+		for _, id := range idList {
+			injectedList[id] = true
+			result.InjectedIDs = append(result.InjectedIDs, id)
+			if !item.Padded {
+				result.RecalledIDs = append(result.RecalledIDs, id)
+			}
+		}
+	}
+
+	if len(result.Included) == 0 {
+		return result
+	}
+
+	result.Content = header + builder.String()
+	result.Tokens = b.count(result.Content)
+	return result
+}
+
+func (b *ContextBuilder) count(text string) int {
+	return len(b.Encoding.Encode(text, nil, nil))
+}
+
+// 截斷到指定 token 數，避免切在多位元組字元中間
+func (b *ContextBuilder) truncate(text string, limit int) string {
+	tokens := b.Encoding.Encode(text, nil, nil)
+	if limit <= 0 {
+		return ""
+	}
+	if len(tokens) <= limit {
+		return text
+	}
+	return strings.ToValidUTF8(b.Encoding.Decode(tokens[:limit]), "")
+}
@@ -0,0 +1,61 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// 每百萬 token 的美元價格
+type Price struct {
+	Input       float64 `json:"input"`
+	CachedInput float64 `json:"cached_input"`
+	Output      float64 `json:"output"`
+}
+
+// 可由 PRICE_TABLE 檔案覆寫或新增
+var PriceList = map[string]Price{
+	"gpt-4o":                    {Input: 2.5, CachedInput: 1.25, Output: 10},
+	"gpt-4o-mini":               {Input: 0.15, CachedInput: 0.075, Output: 0.6},
+	"claude-sonnet-4-20250514":  {Input: 3, CachedInput: 0.3, Output: 15},
+	"claude-3-5-haiku-20241022": {Input: 0.8, CachedInput: 0.08, Output: 4},
+	"gemini-2.5-pro":            {Input: 1.25, CachedInput: 0.31, Output: 10},
+	"gemini-2.5-flash":          {Input: 0.3, CachedInput: 0.075, Output: 2.5},
+}
+
+func GetPrice(model string) (Price, bool) {
+	price, ok := PriceList[model]
+	return price, ok
+}
+
+func (p Price) Cost(usage *Usage) float64 {
+	if usage == nil {
+		return 0
+	}
+	uncached := usage.PromptTokens - usage.CachedTokens
+	return (float64(uncached)*p.Input +
+		float64(usage.CachedTokens)*p.CachedInput +
+		float64(usage.CompletionTokens)*p.Output) / 1_000_000
+}
+
+// 格式：{"model": {"input": 2.5, "cached_input": 1.25, "output": 10}}
+func LoadPriceTable(data []byte) error {
+	var table map[string]Price
+	if err := json.Unmarshal(data, &table); err != nil {
+		return fmt.Errorf("invalid price table: %w", err)
+	}
+
+	for model, price := range table {
+		if price.Input < 0 || price.CachedInput < 0 || price.Output < 0 {
+			return fmt.Errorf("invalid price table: negative price for %s", model)
+		}
+		PriceList[model] = price
+	}
+	return nil
+}
+
+type Budget struct {
+	// 美元上限，0 代表不限制
+	Limit float64
+	// 超出時僅警告，不阻擋送出
+	WarnOnly bool
+}
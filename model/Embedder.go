@@ -0,0 +1,173 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strings"
+)
+
+// 將文字轉為向量，供 Comparer 計算語義相似度
+type Embedder interface {
+	Embed(ctx context.Context, textList []string) ([][]float32, error)
+}
+
+type openAIEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type openAIEmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// OpenAI 相容的 /v1/embeddings，亦可指向 Ollama、llama.cpp 等本地服務
+type OpenAIEmbedder struct {
+	ApiKey  string
+	BaseURL string
+	Model   string
+	Client  *http.Client
+}
+
+func NewOpenAIEmbedder(apiKey, model string) *OpenAIEmbedder {
+	return &OpenAIEmbedder{
+		ApiKey:  apiKey,
+		BaseURL: "https://api.openai.com/v1",
+		Model:   model,
+		Client:  newHTTPClient(),
+	}
+}
+
+func (o *OpenAIEmbedder) Embed(ctx context.Context, textList []string) ([][]float32, error) {
+	body, err := json.Marshal(openAIEmbeddingRequest{
+		Model: o.Model,
+		Input: textList,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(o.BaseURL, "/")+"/embeddings", strings.NewReader(string(body)))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if o.ApiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.ApiKey)
+	}
+
+	res, err := o.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, newAPIError(res)
+	}
+
+	var result openAIEmbeddingResponse
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	vectorList := make([][]float32, len(textList))
+	for _, data := range result.Data {
+		if data.Index >= 0 && data.Index < len(vectorList) {
+			vectorList[data.Index] = data.Embedding
+		}
+	}
+	return vectorList, nil
+}
+
+// 離線且結果固定的向量：將詞與英文字元 n-gram 雜湊到固定維度（feature hashing）
+// 無法理解同義詞，但能捕捉部分拼寫相同的詞，適合無網路或測試環境
+type HashEmbedder struct {
+	Dim int
+}
+
+func NewHashEmbedder(dim int) *HashEmbedder {
+	return &HashEmbedder{
+		Dim: dim,
+	}
+}
+
+func (h *HashEmbedder) Embed(ctx context.Context, textList []string) ([][]float32, error) {
+	vectorList := make([][]float32, len(textList))
+	for i, text := range textList {
+		vectorList[i] = h.embed(text)
+	}
+	return vectorList, nil
+}
+
+func (h *HashEmbedder) embed(text string) []float32 {
+	vector := make([]float32, h.Dim)
+
+	for _, token := range tokenize(text) {
+		if stopList[token] {
+			continue
+		}
+		h.add(vector, token, 1.0)
+
+		// 英文詞加入字元三元組，讓 memory / memories 這類變化也有相似度
+		if token[0] < 0x80 && len(token) > 3 {
+			padded := "<" + token + ">"
+			for i := 0; i+3 <= len(padded); i++ {
+				h.add(vector, padded[i:i+3], 0.5)
+			}
+		}
+	}
+
+	normalize(vector)
+	return vector
+}
+
+func (h *HashEmbedder) add(vector []float32, feature string, weight float32) {
+	hasher := fnv.New32a()
+	hasher.Write([]byte(feature))
+	sum := hasher.Sum32()
+
+	// 以最高位決定正負號，降低雜湊碰撞造成的偏差
+	if sum>>31 == 1 {
+		weight = -weight
+	}
+	vector[sum%uint32(h.Dim)] += weight
+}
+
+func normalize(vector []float32) {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return
+	}
+
+	norm := float32(math.Sqrt(sum))
+	for i := range vector {
+		vector[i] /= norm
+	}
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0.0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0.0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
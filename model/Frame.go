@@ -1,14 +1,14 @@
 package model
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"runtime"
 	"strings"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
-	"github.com/pkoukk/tiktoken-go"
 	"github.com/rivo/tview"
 )
 
@@ -16,13 +16,48 @@ type Frame struct {
 	App             *tview.Application
 	Conversation    *tview.TextView
 	Summary         *tview.TextView
+	Retrieval       *tview.TextView
 	Input           *tview.TextArea
 	CurrentSummary  Summary
 	conversationLog strings.Builder
 	Comparer        *Comparer
+	Provider        Provider
+	LargeModel      string
+	SmallModel      string
+	SessionUsage    Usage
+	ChatCost        float64
+	SummaryCost     float64
+	Budget          Budget
+	// 相關歷史對話可使用的 token 上限
+	MemoryBudget int
+	// 對話保存位置，SessionName 為空時不保存
+	Sessions         *SessionStore
+	SessionName      string
+	EmbedderID       string
+	sessionParent    string
+	sessionCreatedAt time.Time
+	// 舊版 UI 沒有 Comparer，保存時沿用載入的記憶，避免覆寫成空白
+	sessionRecords  []*ConversationRecord
+	sessionEmbedder string
+	pages           *tview.Pages
+	sessionList     *tview.List
+	// 列表項目對應的對話名稱，與畫面上的順序一致
+	sessionNames []string
+	// 概要版本歷史與 Summary 面板目前瀏覽的版本
+	SummaryHistory []SummaryVersion
+	summaryCursor  int
+	summaryDiff    bool
+	// 使用者親自編輯的清單項目，依欄位 key 分組，小模型不可刪除或改寫
+	UserItems map[string][]string
+	cancel    context.CancelFunc
+	requestID int
+	// 檢索除錯面板，預設隱藏
+	layout        *tview.Flex
+	showRetrieval bool
+	LastSearch    SearchReport
 }
 
-func CreateUI() *Frame {
+func CreateUI(provider Provider, largeModel, smallModel string, comparerOptions ...ComparerOption) *Frame {
 	app := tview.NewApplication()
 
 	conversationView := tview.NewTextView().
@@ -45,6 +80,15 @@ func CreateUI() *Frame {
 		SetTitle(" Summary ").
 		SetTitleAlign(tview.AlignLeft)
 
+	retrievalView := tview.NewTextView().
+		SetDynamicColors(true).
+		SetWordWrap(true).
+		SetScrollable(true)
+	retrievalView.
+		SetBorder(true).
+		SetTitle(" Retrieval ").
+		SetTitleAlign(tview.AlignLeft)
+
 	inputField := tview.NewTextArea().
 		SetLabel("Input: ").
 		SetWrap(true).
@@ -62,7 +106,11 @@ func CreateUI() *Frame {
 		AddItem(conversationView, 0, 2, true).
 		AddItem(rightFlex, 0, 1, true)
 
-	app.SetRoot(mainFlex, true).SetFocus(inputField)
+	// 對話列表等視窗以 Pages 疊在主畫面上
+	pages := tview.NewPages().
+		AddPage("main", mainFlex, true, true)
+
+	app.SetRoot(pages, true).SetFocus(inputField)
 
 	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 		switch event.Key() {
@@ -80,38 +128,43 @@ func CreateUI() *Frame {
 		return event
 	})
 
-	summary := Summary{
-		CoreDiscussion:    "empty",
-		ConfirmedNeeds:    []string{},
-		Constraints:       []string{},
-		ExcludedOptions:   []string{},
-		KeyData:           []string{},
-		CurrentConclusion: []string{},
-		PendingQuestions:  []string{},
-		PendingDiscussion: []string{},
-	}
+	summary := NewSummary()
 
-	fuzzySearcher := NewFuzzyComparer(0.3)
+	fuzzySearcher := NewFuzzyComparer(0.3, comparerOptions...)
 
 	frame := &Frame{
 		Conversation:   conversationView,
 		Summary:        summaryView,
+		Retrieval:      retrievalView,
 		Input:          inputField,
 		App:            app,
 		CurrentSummary: summary,
 		Comparer:       fuzzySearcher,
+		Provider:       provider,
+		LargeModel:     largeModel,
+		SmallModel:     smallModel,
+		MemoryBudget:   DefaultMemoryTokenBudget,
+		layout:         mainFlex,
+		pages:          pages,
 	}
 
-	summaryView.SetText(summary.FormatContent())
+	frame.resetSummaryHistory(nil)
+	frame.updateSummary()
+	frame.bindSummaryKeys()
+	retrievalView.SetText(frame.LastSearch.FormatContent())
 
-	now := time.Now().Format("15:04:05")
-	msg := fmt.Sprintf("[gray]%s[white] [green]LLM[white]: Type to start chat\n[yellow]Shortcuts[white]: Type message and end with $$ to send | Tab to Switch Panel | Ctrl+C to Exit\n\n", now)
-	frame.conversationLog.WriteString(msg)
+	frame.writeGreeting()
 	conversationView.SetText(frame.conversationLog.String())
 
 	return frame
 }
 
+func (f *Frame) writeGreeting() {
+	now := time.Now().Format("15:04:05")
+	msg := fmt.Sprintf("[gray]%s[white] [green]LLM[white]: Type to start chat\n[yellow]Shortcuts[white]: Type message and end with $$ to send | Tab to Switch Panel | F2 to Toggle Retrieval | F3 to Manage Sessions | Esc to Abort | Ctrl+C to Exit\n\n", now)
+	f.conversationLog.WriteString(msg)
+}
+
 func (f *Frame) AddToConversation(setTime bool, speaker, message string) {
 	now := time.Now().Format("15:04:05")
 	var msg string
@@ -126,21 +179,194 @@ func (f *Frame) AddToConversation(setTime bool, speaker, message string) {
 	f.Conversation.SetText(f.conversationLog.String())
 	f.Conversation.ScrollToEnd()
 
+	// 只記錄對話內容，狀態與錯誤訊息不進入記憶，避免打斷回合
 	if f.Comparer != nil {
-		speakerType := "user"
 		if strings.Contains(speaker, "LLM") {
-			speakerType = "assistant"
+			f.Comparer.AddRecord("assistant", message)
+		} else if strings.Contains(speaker, "User") {
+			f.Comparer.AddRecord("user", message)
+		}
+	}
+}
+
+// 記錄 API 回報的實際用量並累計到本次對話，需在 UI goroutine 呼叫
+// 對話與概要的費用分開累計，以便比較兩種記憶模式的成本
+func (f *Frame) recordUsage(label, model string, usage *Usage, cost *float64) {
+	if usage == nil {
+		return
+	}
+	f.SessionUsage.Add(usage)
+
+	turnCost := "n/a"
+	if price, ok := GetPrice(model); ok {
+		*cost += price.Cost(usage)
+		turnCost = fmt.Sprintf("$%.4f", price.Cost(usage))
+	}
+	total := f.ChatCost + f.SummaryCost
+
+	f.AddToConversation(false, fmt.Sprintf("[grey]%v[white]", label), fmt.Sprintf("[grey]prompt %d (cached %d) | completion %d | %s | session %d tokens $%.4f (chat $%.4f / summary $%.4f)[white]",
+		usage.PromptTokens,
+		usage.CachedTokens,
+		usage.CompletionTokens,
+		turnCost,
+		f.SessionUsage.Total(),
+		total,
+		f.ChatCost,
+		f.SummaryCost,
+	))
+	f.updateRecordTitle()
+}
+
+// 標題顯示對話名稱與累計用量
+func (f *Frame) updateRecordTitle() {
+	total := f.ChatCost + f.SummaryCost
+	if f.SessionName != "" {
+		f.Conversation.SetTitle(fmt.Sprintf(" Record · %s · %d tokens · $%.4f ", f.SessionName, f.SessionUsage.Total(), total))
+		return
+	}
+	f.Conversation.SetTitle(fmt.Sprintf(" Record · %d tokens · $%.4f ", f.SessionUsage.Total(), total))
+}
+
+// 超出預算時依設定阻擋或僅警告
+func (f *Frame) checkBudget() bool {
+	total := f.ChatCost + f.SummaryCost
+	if f.Budget.Limit <= 0 || total < f.Budget.Limit {
+		return true
+	}
+
+	if f.Budget.WarnOnly {
+		f.AddToConversation(false, fmt.Sprintf("[yellow]%v[white]", "Budget"), fmt.Sprintf("[yellow]session cost $%.4f exceeds budget $%.4f[white]", total, f.Budget.Limit))
+		return true
+	}
+	f.AddToConversation(true, fmt.Sprintf("[red]%v[white]", "Error"), fmt.Sprintf("[red]session cost $%.4f exceeds budget $%.4f, request not sent[white]", total, f.Budget.Limit))
+	return false
+}
+
+// 送出前以本地估算檢查，明顯超出上下文長度時不浪費一次請求
+func (f *Frame) checkContextWindow(estimate int) bool {
+	limit := GetContextWindow(f.LargeModel)
+	if limit == 0 || estimate <= limit {
+		return true
+	}
+	f.AddToConversation(true, fmt.Sprintf("[red]%v[white]", "Error"), fmt.Sprintf("[red]estimated %d tokens exceeds %s context window (%d)[white]", estimate, f.LargeModel, limit))
+	return false
+}
+
+// 已知上下文長度時一併顯示，超出時標紅
+func formatTokenCount(count int, model string) string {
+	limit := GetContextWindow(model)
+	if limit == 0 {
+		return fmt.Sprintf("[grey]%d[white]", count)
+	}
+	if count > limit {
+		return fmt.Sprintf("[red]%d / %d[white]", count, limit)
+	}
+	return fmt.Sprintf("[grey]%d / %d[white]", count, limit)
+}
+
+// 串流期間只渲染畫面，完整回覆仍由 AddToConversation 寫入紀錄與 Comparer
+// 回傳的 Builder 保留已收到的內容，供中斷時記錄部分回覆
+func (f *Frame) streamToConversation(speaker string) (StreamFunc, *strings.Builder) {
+	now := time.Now().Format("15:04:05")
+	partial := &strings.Builder{}
+
+	return func(delta string) {
+		partial.WriteString(delta)
+		msg := fmt.Sprintf("[gray]%s[white] %s: %s\n\n", now, speaker, partial.String())
+
+		f.App.QueueUpdateDraw(func() {
+			f.Conversation.SetText(f.conversationLog.String() + msg)
+			f.Conversation.ScrollToEnd()
+		})
+	}, partial
+}
+
+// 建立可中斷的請求，回傳的 done 需在 UI goroutine 呼叫
+func (f *Frame) startRequest(parent context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(parent)
+	f.requestID++
+	id := f.requestID
+	f.cancel = cancel
+
+	return ctx, func() {
+		cancel()
+		if f.requestID == id {
+			f.cancel = nil
 		}
-		f.Comparer.AddRecord(speakerType, message)
 	}
 }
 
-func (f *Frame) updateSummary() {
-	f.Summary.SetText(f.CurrentSummary.FormatContent())
+// 回覆或概要生成期間拒絕新的輸入，避免覆蓋進行中請求的 cancel
+func (f *Frame) Busy() bool {
+	if f.cancel == nil {
+		return false
+	}
+	f.AddToConversation(false, fmt.Sprintf("[yellow]%v[white]", "Busy"), "[yellow]wait for the current turn to finish or press Esc to abort[white]")
+	return true
+}
+
+// 中斷進行中的請求，沒有請求時回傳 false
+func (f *Frame) Cancel() bool {
+	if f.cancel == nil {
+		return false
+	}
+	f.cancel()
+	f.cancel = nil
+	return true
+}
+
+// 中斷的部分回覆使用的名稱，舊版模式解析紀錄時視為助手回覆
+const interruptedSpeaker = "[green]LLM[white] [grey](interrupted)[white]"
+
+// 中斷時保留已收到的部分回覆，其餘錯誤照常顯示
+func (f *Frame) addResponseError(err error, partial string) {
+	if !errors.Is(err, context.Canceled) {
+		f.AddToConversation(true, fmt.Sprintf("[red]%v[white]", "Error"), fmt.Sprintf("[red]%v[white]", err))
+		return
+	}
+
+	if partial == "" {
+		f.AddToConversation(false, fmt.Sprintf("[grey]%v[white]", "Interrupted"), fmt.Sprintf("[grey]%v[white]", "request cancelled"))
+		return
+	}
+	f.AddToConversation(true, interruptedSpeaker, partial)
+}
+
+// 面板隱藏時不重繪，開啟時才以最新的檢索結果更新
+func (f *Frame) updateRetrieval() {
+	if !f.showRetrieval {
+		return
+	}
+	f.Retrieval.SetText(f.LastSearch.FormatContent())
+	f.Retrieval.ScrollToBeginning()
+}
+
+// 切換檢索除錯面板，顯示上一輪的候選、分數明細與補齊記錄
+func (f *Frame) ToggleRetrieval() {
+	if f.layout == nil {
+		return
+	}
+
+	f.showRetrieval = !f.showRetrieval
+	if f.showRetrieval {
+		f.updateRetrieval()
+		f.layout.AddItem(f.Retrieval, 0, 1, false)
+		return
+	}
+
+	if f.App.GetFocus() == f.Retrieval {
+		f.App.SetFocus(f.Input)
+	}
+	f.layout.RemoveItem(f.Retrieval)
+}
+
+// 除錯面板是否顯示，用於切換焦點
+func (f *Frame) RetrievalVisible() bool {
+	return f.showRetrieval
 }
 
-func (f *Frame) APIHandler(userInput string) {
-	if userInput == "" {
+func (f *Frame) APIHandler(ctx context.Context, userInput string) {
+	if userInput == "" || f.Busy() {
 		return
 	}
 
@@ -172,59 +398,122 @@ func (f *Frame) APIHandler(userInput string) {
 	}
 
 	f.AddToConversation(true, fmt.Sprintf("[yellow]%v[white]", "User"), userInput)
+	queryID := f.Comparer.Len()
 
-	// 使用模糊搜尋找到相關歷史對話
-	relevantRecords := f.Comparer.Search(userInput)
-	relevantContext := strings.TrimSpace(f.Comparer.FormatRelevant(relevantRecords))
-
-	// 構建包含相關歷史的上下文
-	if relevantContext != "" {
-		messages = append(messages, Message{
-			Role:    "system",
-			Content: relevantContext,
-		})
-	}
-
-	messages = append(messages, Message{
-		Role:    "user",
-		Content: userInput,
-	})
-
-	// 使用 tiktoken 來計算 token 數量 for gpt-4o
-	tke, err := tiktoken.GetEncoding("o200k_base")
+	// 依模型選擇編碼計算 token 數量
+	tke, err := encodingForModel(f.LargeModel)
 	if err != nil {
 		f.AddToConversation(true, fmt.Sprintf("[red]%v[white]", "錯誤"), fmt.Sprintf("[red]%v[white]", err))
 		return
 	}
 
-	systemToken := tke.Encode(systemPrompt+systemSummary+relevantContext, nil, nil)
-	inputToken := tke.Encode(userInput, nil, nil)
+	fixedToken := len(tke.Encode(systemPrompt+systemSummary+userInput, nil, nil))
+
+	// 相關歷史不超過設定上限，也不擠掉系統提示、概要與預留的回覆空間
+	memoryBudget := f.MemoryBudget
+	if limit := GetContextWindow(f.LargeModel); limit > 0 {
+		memoryBudget = min(memoryBudget, limit-fixedToken-responseTokenReserve)
+	}
+	memoryBudget = max(memoryBudget, 0)
 
-	f.AddToConversation(false, fmt.Sprintf("[grey]%v[white]", "Request token"), fmt.Sprintf("[grey]%d[white]", len(inputToken)+len(systemToken)))
+	// 檢索可能需要計算查詢向量，與回覆一樣在背景執行並可中斷
+	ctx, done := f.startRequest(ctx)
 
 	go func() {
-		response, err := askWithLargeModel(messages)
+		// 使用模糊搜尋找到相關歷史對話，依 token 預算組合
+		report := f.Comparer.Explain(ctx, userInput, queryID)
+		builder := ContextBuilder{Encoding: tke, Budget: memoryBudget}
+		relevant := builder.Build(report.Results, queryID)
+		report.Injected = relevant.InjectedIDs
+		for _, result := range relevant.Dropped {
+			report.Dropped = append(report.Dropped, result.Record.ID)
+		}
+
+		relevantContext := strings.TrimSpace(relevant.Content)
+
+		// 構建包含相關歷史的上下文
+		if relevantContext != "" {
+			messages = append(messages, Message{
+				Role:    "system",
+				Content: relevantContext,
+			})
+		}
+
+		messages = append(messages, Message{
+			Role:    "user",
+			Content: userInput,
+		})
 
 		f.App.QueueUpdateDraw(func() {
-			if err != nil {
-				f.AddToConversation(true, fmt.Sprintf("[red]%v[white]", "Error"), fmt.Sprintf("[red]%v[white]", err))
+			if err := ctx.Err(); err != nil {
+				done()
+				f.addResponseError(err, "")
+				f.saveSession()
 				return
 			}
 
-			f.AddToConversation(true, fmt.Sprintf("[green]%v[white]", "LLM"), response)
+			f.LastSearch = report
+			f.updateRetrieval()
+
+			f.AddToConversation(false, fmt.Sprintf("[grey]%v[white]", "Memory"), fmt.Sprintf("[grey]%d records, %d / %d tokens, %d truncated, %d dropped[white]",
+				len(relevant.Included),
+				relevant.Tokens,
+				relevant.Budget,
+				len(relevant.Truncated),
+				len(relevant.Dropped),
+			))
+			f.AddToConversation(false, fmt.Sprintf("[grey]%v[white]", "Estimated token"), formatTokenCount(fixedToken+relevant.Tokens, f.LargeModel))
+			if !f.checkContextWindow(fixedToken+relevant.Tokens) || !f.checkBudget() {
+				done()
+				return
+			}
 
-			go func() {
-				newSummary := f.generateSummary(f.CurrentSummary, userInput, response)
-				f.App.QueueUpdateDraw(func() {
-					f.CurrentSummary = newSummary
-					f.updateSummary()
-				})
-			}()
+			// 確定送出才強化被注入的記錄，被阻擋的請求不算被想起
+			f.Comparer.Reinforce(relevant.RecalledIDs)
+			go f.askConversation(ctx, done, messages, userInput)
 		})
 	}()
 }
 
-func (f *Frame) generateSummary(summary Summary, input, assistant string) Summary {
+// 送出對話並在完成後更新概要，done 在概要更新後才呼叫，期間不接受新的輸入
+func (f *Frame) askConversation(ctx context.Context, done func(), messages []Message, userInput string) {
+	onDelta, partial := f.streamToConversation(fmt.Sprintf("[green]%v[white]", "LLM"))
+	response, err := f.askWithLargeModel(ctx, messages, onDelta)
+
+	f.App.QueueUpdateDraw(func() {
+		if err != nil {
+			done()
+			f.addResponseError(err, partial.String())
+			f.saveSession()
+			return
+		}
+
+		f.AddToConversation(true, fmt.Sprintf("[green]%v[white]", "LLM"), response.Content)
+		f.recordUsage("Usage", f.LargeModel, response.Usage, &f.ChatCost)
+
+		go func() {
+			newSummary := f.generateSummary(ctx, f.CurrentSummary, f.UserItems, userInput, response.Content)
+			f.App.QueueUpdateDraw(func() {
+				done()
+
+				// 模型遺漏的累加項目與使用者編輯的項目補回並記錄，被改寫的項目也記錄以便復原
+				merged, restoredList, replacedList := mergeSummary(f.CurrentSummary, newSummary, f.UserItems)
+				if len(replacedList) > 0 {
+					f.AddToConversation(false, fmt.Sprintf("[yellow]%v[white]", "Summary guard"), fmt.Sprintf("[yellow]%v[white]", tview.Escape(formatReplaced(replacedList))))
+				}
+				if len(restoredList) > 0 {
+					f.AddToConversation(false, fmt.Sprintf("[yellow]%v[white]", "Summary guard"), fmt.Sprintf("[yellow]%v[white]", tview.Escape(formatRestored(restoredList))))
+					f.setSummary(merged, "model+merge")
+				} else {
+					f.setSummary(newSummary, "model")
+				}
+				f.saveSession()
+			})
+		}()
+	})
+}
+
+func (f *Frame) generateSummary(ctx context.Context, summary Summary, userItems map[string][]string, input, assistant string) Summary {
 	prompt := fmt.Sprintf(`基於以下資訊更新對話概要，保持 JSON 格式：
 
 當前概要：
@@ -251,7 +540,7 @@ JSON 格式要求：
 只回傳 JSON，不要其他說明。
 
 請更新概要：`,
-		summary.FormatContext(),
+		summary.FormatContext()+formatUserItems(userItems),
 		input,
 		assistant,
 		InstructionSummary,
@@ -268,38 +557,80 @@ JSON 格式要求：
 		},
 	}
 
-	// 使用 tiktoken 來計算 token 數量 for gpt-4o
-	tke, err := tiktoken.GetEncoding("o200k_base")
+	// 依模型選擇編碼計算 token 數量
+	tke, err := encodingForModel(f.SmallModel)
 	if err != nil {
-		f.AddToConversation(true, fmt.Sprintf("[red]%v[white]", "錯誤"), fmt.Sprintf("[red]%v[white]", err))
+		f.App.QueueUpdateDraw(func() {
+			f.AddToConversation(true, fmt.Sprintf("[red]%v[white]", "錯誤"), fmt.Sprintf("[red]%v[white]", err))
+		})
 		return summary
 	}
 
 	promptToken := tke.Encode(prompt, nil, nil)
 	systemToken := tke.Encode("你是一個專業的對話概要整理助手。請根據對話內容提取並更新概要，保持 JSON 格式輸出。", nil, nil)
 
-	f.AddToConversation(false, fmt.Sprintf("[grey]%v[white]", "Summary token"), fmt.Sprintf("[grey]%d[white]", len(promptToken)+len(systemToken)))
+	f.App.QueueUpdateDraw(func() {
+		f.AddToConversation(false, fmt.Sprintf("[grey]%v[white]", "Estimated summary token"), formatTokenCount(len(promptToken)+len(systemToken), f.SmallModel))
+	})
 
-	response, err := askWithSmallModel(messages)
+	response, err := f.askSummary(ctx, messages)
 	if err != nil {
-		f.AddToConversation(true, fmt.Sprintf("[red]%v[white]", "錯誤"), fmt.Sprintf("[red]%v[white]", err))
 		return summary
 	}
 
-	result := strings.TrimSpace(response)
-	result = strings.TrimPrefix(result, "```json")
-	result = strings.TrimSuffix(result, "```")
-	result = strings.TrimSpace(result)
+	newSummary, err := parseSummary(response.Content)
+	if err != nil {
+		// 解析失敗時附上錯誤重新詢問一次
+		messages = append(messages,
+			Message{
+				Role:    "assistant",
+				Content: response.Content,
+			},
+			Message{
+				Role:    "user",
+				Content: fmt.Sprintf("上述輸出無法解析為 JSON：%v\n請修正後只回傳符合格式的 JSON。", err),
+			},
+		)
+
+		response, err = f.askSummary(ctx, messages)
+		if err != nil {
+			return summary
+		}
 
-	var newSummary Summary
-	if err := json.Unmarshal([]byte(result), &newSummary); err != nil {
-		return summary
+		newSummary, err = parseSummary(response.Content)
+		if err != nil {
+			f.App.QueueUpdateDraw(func() {
+				f.AddToConversation(true, fmt.Sprintf("[red]%v[white]", "Summary error"), fmt.Sprintf("[red]keeping previous summary: %v[white]", err))
+			})
+			return summary
+		}
 	}
 
 	return newSummary
 }
 
-func CreateOldUI() *Frame {
+// 呼叫小模型並記錄用量，失敗時直接顯示錯誤
+// 在背景 goroutine 執行，用量與錯誤都交由 UI goroutine 寫入
+func (f *Frame) askSummary(ctx context.Context, messages []Message) (*ChatResponse, error) {
+	response, err := f.askWithSmallModel(ctx, messages)
+
+	f.App.QueueUpdateDraw(func() {
+		switch {
+		case errors.Is(err, context.Canceled):
+			f.AddToConversation(false, fmt.Sprintf("[grey]%v[white]", "Summary"), fmt.Sprintf("[grey]%v[white]", "cancelled, keeping previous summary"))
+		case err != nil:
+			f.AddToConversation(true, fmt.Sprintf("[red]%v[white]", "錯誤"), fmt.Sprintf("[red]%v[white]", err))
+		default:
+			f.recordUsage("Summary usage", f.SmallModel, response.Usage, &f.SummaryCost)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func CreateOldUI(provider Provider, largeModel, smallModel string) *Frame {
 	app := tview.NewApplication()
 
 	conversationView := tview.NewTextView().
@@ -348,18 +679,21 @@ func CreateOldUI() *Frame {
 		Conversation: conversationView,
 		Input:        inputField,
 		App:          app,
+		Provider:     provider,
+		LargeModel:   largeModel,
+		SmallModel:   smallModel,
 	}
 
 	now := time.Now().Format("15:04:05")
-	msg := fmt.Sprintf("[gray]%s[white] [green]LLM[white]: Type to start chat\n[yellow]Shortcuts[white]: Type message and end with $$ to send | Tab to Switch Panel | Ctrl+C to Exit\n\n", now)
+	msg := fmt.Sprintf("[gray]%s[white] [green]LLM[white]: Type to start chat\n[yellow]Shortcuts[white]: Type message and end with $$ to send | Tab to Switch Panel | Esc to Abort | Ctrl+C to Exit\n\n", now)
 	frame.conversationLog.WriteString(msg)
 	conversationView.SetText(frame.conversationLog.String())
 
 	return frame
 }
 
-func (f *Frame) OldAPIHandler(userInput string) {
-	if userInput == "" {
+func (f *Frame) OldAPIHandler(ctx context.Context, userInput string) {
+	if userInput == "" || f.Busy() {
 		return
 	}
 
@@ -387,36 +721,14 @@ func (f *Frame) OldAPIHandler(userInput string) {
 		},
 	}
 
-	conversationText := f.conversationLog.String()
-	lines := strings.Split(conversationText, "\n")
-
-	for _, line := range lines {
-		// 修正解析邏輯，處理顏色標記
-		if strings.Contains(line, "[yellow]User[white]:") {
-			parts := strings.SplitN(line, "[yellow]User[white]: ", 2)
-			if len(parts) > 1 {
-				messages = append(messages, Message{
-					Role:    "user",
-					Content: parts[1],
-				})
-			}
-		} else if strings.Contains(line, "[green]LLM[white]:") {
-			parts := strings.SplitN(line, "[green]LLM[white]: ", 2)
-			if len(parts) > 1 {
-				messages = append(messages, Message{
-					Role:    "assistant",
-					Content: parts[1],
-				})
-			}
-		}
-	}
+	messages = append(messages, parseConversationLog(f.conversationLog.String())...)
 
 	messages = append(messages, Message{
 		Role:    "user",
 		Content: userInput,
 	})
 
-	tke, err := tiktoken.GetEncoding("o200k_base")
+	tke, err := encodingForModel(f.LargeModel)
 	if err != nil {
 		f.AddToConversation(true, fmt.Sprintf("[red]%v[white]", "錯誤"), fmt.Sprintf("[red]%v[white]", err))
 		return
@@ -428,18 +740,65 @@ func (f *Frame) OldAPIHandler(userInput string) {
 		totalTokens += len(tokens)
 	}
 
-	f.AddToConversation(false, fmt.Sprintf("[grey]%v[white]", "Request token"), fmt.Sprintf("[grey]%d[white]", totalTokens))
+	f.AddToConversation(false, fmt.Sprintf("[grey]%v[white]", "Estimated token"), formatTokenCount(totalTokens, f.LargeModel))
+	if !f.checkContextWindow(totalTokens) || !f.checkBudget() {
+		return
+	}
+
+	ctx, done := f.startRequest(ctx)
 
 	go func() {
-		response, err := askWithLargeModel(messages)
+		onDelta, partial := f.streamToConversation(fmt.Sprintf("[green]%v[white]", "LLM"))
+		response, err := f.askWithLargeModel(ctx, messages, onDelta)
 
 		f.App.QueueUpdateDraw(func() {
+			done()
 			if err != nil {
-				f.AddToConversation(true, fmt.Sprintf("[red]%v[white]", "Error"), fmt.Sprintf("[red]%v[white]", err))
+				f.addResponseError(err, partial.String())
+				f.saveSession()
 				return
 			}
 
-			f.AddToConversation(true, fmt.Sprintf("[green]%v[white]", "LLM"), response)
+			f.AddToConversation(true, fmt.Sprintf("[green]%v[white]", "LLM"), response.Content)
+			f.recordUsage("Usage", f.LargeModel, response.Usage, &f.ChatCost)
+			f.saveSession()
 		})
 	}()
 }
+
+// 從紀錄中取回使用者與助手的對話，中斷的部分回覆也視為助手回覆
+func parseConversationLog(conversationText string) []Message {
+	messages := make([]Message, 0)
+	lines := strings.Split(conversationText, "\n")
+
+	for _, line := range lines {
+		// 修正解析邏輯，處理顏色標記
+		if strings.Contains(line, "[yellow]User[white]:") {
+			parts := strings.SplitN(line, "[yellow]User[white]: ", 2)
+			if len(parts) > 1 {
+				messages = append(messages, Message{
+					Role:    "user",
+					Content: parts[1],
+				})
+			}
+		} else if strings.Contains(line, "[green]LLM[white]:") {
+			parts := strings.SplitN(line, "[green]LLM[white]: ", 2)
+			if len(parts) > 1 {
+				messages = append(messages, Message{
+					Role:    "assistant",
+					Content: parts[1],
+				})
+			}
+		} else if strings.Contains(line, interruptedSpeaker+":") {
+			parts := strings.SplitN(line, interruptedSpeaker+": ", 2)
+			if len(parts) > 1 {
+				messages = append(messages, Message{
+					Role:    "assistant",
+					Content: parts[1],
+				})
+			}
+		}
+	}
+
+	return messages
+}
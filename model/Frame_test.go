@@ -0,0 +1,35 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/rivo/tview"
+)
+
+// 舊版模式以紀錄作為下一輪的完整歷史，中斷的部分回覆不可遺失
+func TestParseConversationLogInterrupted(t *testing.T) {
+	frame := &Frame{Conversation: tview.NewTextView()}
+	frame.AddToConversation(true, fmt.Sprintf("[yellow]%v[white]", "User"), "first question")
+	frame.AddToConversation(true, fmt.Sprintf("[green]%v[white]", "LLM"), "first answer")
+	frame.AddToConversation(false, fmt.Sprintf("[grey]%v[white]", "Usage"), "[grey]prompt 10[white]")
+	frame.AddToConversation(true, fmt.Sprintf("[yellow]%v[white]", "User"), "second question")
+	frame.addResponseError(context.Canceled, "partial answer")
+
+	messages := parseConversationLog(frame.conversationLog.String())
+	want := []Message{
+		{Role: "user", Content: "first question"},
+		{Role: "assistant", Content: "first answer"},
+		{Role: "user", Content: "second question"},
+		{Role: "assistant", Content: "partial answer"},
+	}
+	if len(messages) != len(want) {
+		t.Fatalf("messages = %+v, want %+v", messages, want)
+	}
+	for i := range want {
+		if messages[i] != want[i] {
+			t.Errorf("messages[%d] = %+v, want %+v", i, messages[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package model
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiGenerationConfig struct {
+	ResponseMimeType   string         `json:"responseMimeType,omitempty"`
+	ResponseJsonSchema map[string]any `json:"responseJsonSchema,omitempty"`
+}
+
+type geminiRequest struct {
+	Contents          []geminiContent         `json:"contents"`
+	SystemInstruction *geminiContent          `json:"systemInstruction,omitempty"`
+	GenerationConfig  *geminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type geminiResponse struct {
+	Candidates []struct {
+		Content geminiContent `json:"content"`
+	} `json:"candidates"`
+	UsageMetadata *struct {
+		PromptTokenCount        int `json:"promptTokenCount"`
+		CandidatesTokenCount    int `json:"candidatesTokenCount"`
+		CachedContentTokenCount int `json:"cachedContentTokenCount"`
+	} `json:"usageMetadata"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+}
+
+type Gemini struct {
+	ApiKey  string
+	BaseURL string
+	Client  *http.Client
+}
+
+func NewGemini(apiKey string) *Gemini {
+	return &Gemini{
+		ApiKey:  apiKey,
+		BaseURL: "https://generativelanguage.googleapis.com",
+		Client:  newHTTPClient(),
+	}
+}
+
+// system 訊息合併為 systemInstruction，assistant 對應 Gemini 的 model 角色
+func toGeminiContents(msgList []Message) (*geminiContent, []geminiContent) {
+	var system *geminiContent
+	contents := make([]geminiContent, 0, len(msgList))
+
+	for _, msg := range msgList {
+		if msg.Role == "system" {
+			if strings.TrimSpace(msg.Content) == "" {
+				continue
+			}
+			if system == nil {
+				system = &geminiContent{}
+			}
+			system.Parts = append(system.Parts, geminiPart{Text: msg.Content})
+			continue
+		}
+
+		role := "user"
+		if msg.Role == "assistant" {
+			role = "model"
+		}
+		contents = append(contents, geminiContent{
+			Role:  role,
+			Parts: []geminiPart{{Text: msg.Content}},
+		})
+	}
+
+	return system, contents
+}
+
+func (g *Gemini) Chat(ctx context.Context, chatReq ChatRequest) (*ChatResponse, error) {
+	system, contents := toGeminiContents(chatReq.Messages)
+
+	reqBody := geminiRequest{
+		Contents:          contents,
+		SystemInstruction: system,
+	}
+	if chatReq.JSONMode || chatReq.Schema != nil {
+		reqBody.GenerationConfig = &geminiGenerationConfig{
+			ResponseMimeType: "application/json",
+		}
+		if chatReq.Schema != nil {
+			reqBody.GenerationConfig.ResponseJsonSchema = chatReq.Schema.Schema
+		}
+	}
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := fmt.Sprintf("%s/v1beta/models/%s:streamGenerateContent?alt=sse",
+		strings.TrimSuffix(g.BaseURL, "/"),
+		url.PathEscape(chatReq.Model),
+	)
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(string(body)))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-goog-api-key", g.ApiKey)
+
+	res, err := g.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, newAPIError(res)
+	}
+
+	var result strings.Builder
+	var usage *Usage
+	reader := bufio.NewReader(res.Body)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		lineStr := strings.TrimSpace(string(line))
+		if !strings.HasPrefix(lineStr, "data:") {
+			continue
+		}
+		jsonData := strings.TrimSpace(strings.TrimPrefix(lineStr, "data:"))
+
+		var stream geminiResponse
+		if err := json.Unmarshal([]byte(jsonData), &stream); err != nil {
+			continue
+		}
+
+		if stream.Error != nil {
+			return nil, &APIError{
+				Kind:       classifyStatus(stream.Error.Code, stream.Error.Message),
+				StatusCode: stream.Error.Code,
+				Message:    stream.Error.Status + ": " + stream.Error.Message,
+			}
+		}
+
+		// 每個區塊都會帶累計用量，以最後一次為準
+		if stream.UsageMetadata != nil {
+			usage = &Usage{
+				PromptTokens:     stream.UsageMetadata.PromptTokenCount,
+				CompletionTokens: stream.UsageMetadata.CandidatesTokenCount,
+				CachedTokens:     stream.UsageMetadata.CachedContentTokenCount,
+			}
+		}
+
+		if len(stream.Candidates) == 0 {
+			continue
+		}
+		for _, part := range stream.Candidates[0].Content.Parts {
+			if part.Text == "" {
+				continue
+			}
+			result.WriteString(part.Text)
+			if chatReq.OnDelta != nil {
+				chatReq.OnDelta(part.Text)
+			}
+		}
+	}
+
+	return &ChatResponse{
+		Content: result.String(),
+		Usage:   usage,
+	}, nil
+}
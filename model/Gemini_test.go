@@ -0,0 +1,176 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 回放 testdata 中錄製的 SSE 回應，並保存收到的請求內容
+func newGeminiServer(t *testing.T, fixture string, request *geminiRequest) *httptest.Server {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1beta/models/gemini-test:streamGenerateContent" || r.URL.Query().Get("alt") != "sse" {
+			t.Errorf("url = %q", r.URL.String())
+		}
+		if r.Header.Get("x-goog-api-key") != "test-key" {
+			t.Errorf("x-goog-api-key = %q, want test-key", r.Header.Get("x-goog-api-key"))
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		if request != nil {
+			if err := json.Unmarshal(body, request); err != nil {
+				t.Errorf("invalid request body: %v", err)
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestGemini(baseURL string) *Gemini {
+	gemini := NewGemini("test-key")
+	gemini.BaseURL = baseURL
+	return gemini
+}
+
+func TestGeminiChatStream(t *testing.T) {
+	var request geminiRequest
+	server := newGeminiServer(t, "gemini_stream.sse", &request)
+
+	deltaList := make([]string, 0)
+	response, err := newTestGemini(server.URL).Chat(context.Background(), ChatRequest{
+		Model: "gemini-test",
+		Messages: []Message{
+			{Role: "system", Content: "system prompt"},
+			{Role: "system", Content: "summary"},
+			{Role: "user", Content: "question"},
+			{Role: "assistant", Content: "answer"},
+			{Role: "system", Content: ""},
+			{Role: "user", Content: "follow-up"},
+		},
+		OnDelta: func(delta string) {
+			deltaList = append(deltaList, delta)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// system 訊息依序成為 systemInstruction 的 parts，assistant 對應 model
+	if request.SystemInstruction == nil || len(request.SystemInstruction.Parts) != 2 ||
+		request.SystemInstruction.Parts[0].Text != "system prompt" || request.SystemInstruction.Parts[1].Text != "summary" {
+		t.Errorf("systemInstruction = %+v", request.SystemInstruction)
+	}
+	roleList := make([]string, 0, len(request.Contents))
+	for _, content := range request.Contents {
+		roleList = append(roleList, content.Role+":"+content.Parts[0].Text)
+	}
+	if strings.Join(roleList, ",") != "user:question,model:answer,user:follow-up" {
+		t.Errorf("contents = %q", roleList)
+	}
+	if request.GenerationConfig != nil {
+		t.Errorf("generationConfig = %+v, want none", request.GenerationConfig)
+	}
+
+	if response.Content != "Hello, world" {
+		t.Errorf("content = %q", response.Content)
+	}
+	if strings.Join(deltaList, "|") != "Hello|, world" {
+		t.Errorf("deltas = %q", deltaList)
+	}
+
+	// 用量以最後一個區塊為準
+	want := Usage{PromptTokens: 42, CompletionTokens: 5, CachedTokens: 16}
+	if response.Usage == nil || *response.Usage != want {
+		t.Errorf("usage = %+v, want %+v", response.Usage, want)
+	}
+}
+
+func TestGeminiChatSchema(t *testing.T) {
+	var request geminiRequest
+	server := newGeminiServer(t, "gemini_stream_json.sse", &request)
+
+	response, err := newTestGemini(server.URL).Chat(context.Background(), ChatRequest{
+		Model:    "gemini-test",
+		Messages: []Message{{Role: "user", Content: "summarize"}},
+		Schema:   &JSONSchema{Name: "summary", Schema: SchemaOf(Summary{})},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := request.GenerationConfig
+	if config == nil || config.ResponseMimeType != "application/json" {
+		t.Fatalf("generationConfig = %+v", config)
+	}
+	if config.ResponseJsonSchema["type"] != "object" {
+		t.Errorf("responseJsonSchema = %+v", config.ResponseJsonSchema)
+	}
+	if properties, ok := config.ResponseJsonSchema["properties"].(map[string]any); !ok || properties["core_discussion"] == nil {
+		t.Errorf("responseJsonSchema properties = %+v", config.ResponseJsonSchema["properties"])
+	}
+
+	var summary Summary
+	if err := json.Unmarshal([]byte(response.Content), &summary); err != nil {
+		t.Fatalf("content %q is not JSON: %v", response.Content, err)
+	}
+	if summary.CoreDiscussion != "test" {
+		t.Errorf("core_discussion = %q", summary.CoreDiscussion)
+	}
+}
+
+func TestGeminiChatJSONMode(t *testing.T) {
+	var request geminiRequest
+	server := newGeminiServer(t, "gemini_stream_json.sse", &request)
+
+	_, err := newTestGemini(server.URL).Chat(context.Background(), ChatRequest{
+		Model:    "gemini-test",
+		Messages: []Message{{Role: "user", Content: "summarize"}},
+		JSONMode: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := request.GenerationConfig
+	if config == nil || config.ResponseMimeType != "application/json" || config.ResponseJsonSchema != nil {
+		t.Errorf("generationConfig = %+v, want JSON mime type without schema", config)
+	}
+}
+
+func TestGeminiChatStreamError(t *testing.T) {
+	server := newGeminiServer(t, "gemini_stream_error.sse", nil)
+
+	_, err := newTestGemini(server.URL).Chat(context.Background(), ChatRequest{
+		Model:    "gemini-test",
+		Messages: []Message{{Role: "user", Content: "question"}},
+	})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want *APIError", err)
+	}
+	if apiErr.Kind != ErrServer || apiErr.StatusCode != 503 || !apiErr.Retryable() {
+		t.Errorf("kind = %v, status = %d, want retryable 503", apiErr.Kind, apiErr.StatusCode)
+	}
+	if !strings.HasPrefix(apiErr.Message, "UNAVAILABLE: ") {
+		t.Errorf("message = %q", apiErr.Message)
+	}
+}
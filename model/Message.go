@@ -1,12 +1,10 @@
 package model
 
 import (
-	"bufio"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
-	"strings"
+	"time"
 )
 
 type Message struct {
@@ -14,96 +12,43 @@ type Message struct {
 	Content string `json:"content"`
 }
 
-type Request struct {
-	Model    string    `json:"model"`
-	Messages []Message `json:"messages"`
-	Stream   bool      `json:"stream"`
+var summarySchema = &JSONSchema{
+	Name:   "summary",
+	Schema: SchemaOf(Summary{}),
 }
 
-type Response struct {
-	Choices []struct {
-		Delta struct {
-			Content string `json:"content"`
-		} `json:"delta"`
-	} `json:"choices"`
-}
-
-func askWithSmallModel(msgList []Message) (string, error) {
-	return askWithChatGPT("gpt-4o-mini", msgList)
-}
-
-func askWithLargeModel(msgList []Message) (string, error) {
-	return askWithChatGPT("gpt-4o", msgList)
+// 概要只接受 JSON，支援的供應商會依 Summary 結構強制輸出
+func (f *Frame) askWithSmallModel(ctx context.Context, msgList []Message) (*ChatResponse, error) {
+	return f.ask(ctx, ChatRequest{
+		Model:    f.SmallModel,
+		Messages: msgList,
+		JSONMode: true,
+		Schema:   summarySchema,
+	})
 }
 
-func askWithChatGPT(model string, msgList []Message) (string, error) {
-	body, err := json.Marshal(Request{
-		Model:    model,
+func (f *Frame) askWithLargeModel(ctx context.Context, msgList []Message, onDelta StreamFunc) (*ChatResponse, error) {
+	return f.ask(ctx, ChatRequest{
+		Model:    f.LargeModel,
 		Messages: msgList,
-		Stream:   true,
+		OnDelta:  onDelta,
 	})
-	if err != nil {
-		return "", err
-	}
-
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", strings.NewReader(string(body)))
-	if err != nil {
-		return "", err
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+ApiKey)
-
-	client := &http.Client{}
-	res, err := client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer res.Body.Close()
-
-	if res.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(res.Body)
-		return "", fmt.Errorf("API Error (Status %d): %s", res.StatusCode, string(bodyBytes))
-	}
-
-	var result strings.Builder
-	reader := bufio.NewReader(res.Body)
-
-	for {
-		line, err := reader.ReadBytes('\n')
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return "", err
-		}
-
-		lineStr := string(line)
-		if strings.TrimSpace(lineStr) == "" {
-			continue
-		}
-
-		if !strings.HasPrefix(lineStr, "data: ") {
-			continue
-		}
-		jsonData := strings.TrimPrefix(strings.TrimSpace(lineStr), "data: ")
-
-		if jsonData == "[DONE]" {
-			continue
-		}
+}
 
-		var stream Response
-		if err := json.Unmarshal([]byte(jsonData), &stream); err != nil {
-			continue
-		}
+func (f *Frame) ask(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	req.OnRetry = f.showRetry
+	return f.Provider.Chat(ctx, req)
+}
 
-		if len(stream.Choices) > 0 {
-			content := stream.Choices[0].Delta.Content
-			if content != "" {
-				result.WriteString(content)
-			}
-		}
+// 由請求的 goroutine 呼叫，需排入 UI 更新
+func (f *Frame) showRetry(attempt, maxAttempts int, err error, wait time.Duration) {
+	reason := err.Error()
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		reason = apiErr.Kind.String()
 	}
 
-	return result.String(), nil
+	f.App.QueueUpdateDraw(func() {
+		f.AddToConversation(false, fmt.Sprintf("[grey]%v[white]", "Retry"), fmt.Sprintf("[grey]retrying (%d/%d) in %s: %s[white]", attempt, maxAttempts, wait.Round(100*time.Millisecond), reason))
+	})
 }
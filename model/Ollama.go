@@ -0,0 +1,140 @@
+package model
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type ollamaOptions struct {
+	NumCtx int `json:"num_ctx,omitempty"`
+}
+
+type ollamaRequest struct {
+	Model    string         `json:"model"`
+	Messages []Message      `json:"messages"`
+	Stream   bool           `json:"stream"`
+	Format   any            `json:"format,omitempty"`
+	Options  *ollamaOptions `json:"options,omitempty"`
+}
+
+type ollamaResponse struct {
+	Message struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	Done            bool   `json:"done"`
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+	Error           string `json:"error"`
+}
+
+// Ollama 原生 /api/chat，串流格式為逐行 JSON 而非 SSE
+type Ollama struct {
+	ApiKey  string
+	BaseURL string
+	Client  *http.Client
+}
+
+func NewOllama(baseURL string) *Ollama {
+	if baseURL == "" {
+		baseURL = "http://localhost:11434"
+	}
+	return &Ollama{
+		BaseURL: baseURL,
+		Client:  newHTTPClient(),
+	}
+}
+
+func (o *Ollama) Chat(ctx context.Context, chatReq ChatRequest) (*ChatResponse, error) {
+	reqBody := ollamaRequest{
+		Model:    chatReq.Model,
+		Messages: chatReq.Messages,
+		Stream:   true,
+	}
+	// format 可為 "json" 或完整的 JSON Schema
+	switch {
+	case chatReq.Schema != nil:
+		reqBody.Format = chatReq.Schema.Schema
+	case chatReq.JSONMode:
+		reqBody.Format = "json"
+	}
+	// Ollama 預設上下文很短，有設定時明確指定避免長提示被截斷
+	if size, ok := ContextWindowList[chatReq.Model]; ok {
+		reqBody.Options = &ollamaOptions{NumCtx: size}
+	}
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(o.BaseURL, "/")+"/api/chat", strings.NewReader(string(body)))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if o.ApiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.ApiKey)
+	}
+
+	res, err := o.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, newAPIError(res)
+	}
+
+	var result strings.Builder
+	var usage *Usage
+	reader := bufio.NewReader(res.Body)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		if jsonData := strings.TrimSpace(string(line)); jsonData != "" {
+			var stream ollamaResponse
+			if json.Unmarshal([]byte(jsonData), &stream) == nil {
+				if stream.Error != "" {
+					return nil, &APIError{
+						Kind:    classifyStatus(0, stream.Error),
+						Message: stream.Error,
+					}
+				}
+
+				if content := stream.Message.Content; content != "" {
+					result.WriteString(content)
+					if chatReq.OnDelta != nil {
+						chatReq.OnDelta(content)
+					}
+				}
+
+				if stream.Done {
+					usage = &Usage{
+						PromptTokens:     stream.PromptEvalCount,
+						CompletionTokens: stream.EvalCount,
+					}
+					break
+				}
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	return &ChatResponse{
+		Content: result.String(),
+		Usage:   usage,
+	}, nil
+}
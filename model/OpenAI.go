@@ -0,0 +1,171 @@
+package model
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIJSONSchema struct {
+	Name   string         `json:"name"`
+	Schema map[string]any `json:"schema"`
+	Strict bool           `json:"strict"`
+}
+
+type openAIResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *openAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+type openAIRequest struct {
+	Model          string                `json:"model"`
+	Messages       []Message             `json:"messages"`
+	Stream         bool                  `json:"stream"`
+	StreamOptions  *openAIStreamOptions  `json:"stream_options,omitempty"`
+	ResponseFormat *openAIResponseFormat `json:"response_format,omitempty"`
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	// 開啟 include_usage 後，最後一個區塊的 choices 為空並帶有用量
+	Usage *struct {
+		PromptTokens        int `json:"prompt_tokens"`
+		CompletionTokens    int `json:"completion_tokens"`
+		PromptTokensDetails struct {
+			CachedTokens int `json:"cached_tokens"`
+		} `json:"prompt_tokens_details"`
+	} `json:"usage"`
+}
+
+// 也適用於 llama.cpp、vLLM、Ollama 等 OpenAI 相容端點，本地端點可不設定金鑰
+type OpenAI struct {
+	ApiKey  string
+	BaseURL string
+	Client  *http.Client
+}
+
+func NewOpenAI(apiKey string) *OpenAI {
+	return &OpenAI{
+		ApiKey:  apiKey,
+		BaseURL: "https://api.openai.com/v1",
+		Client:  newHTTPClient(),
+	}
+}
+
+func (o *OpenAI) Chat(ctx context.Context, chatReq ChatRequest) (*ChatResponse, error) {
+	reqBody := openAIRequest{
+		Model:    chatReq.Model,
+		Messages: chatReq.Messages,
+		Stream:   true,
+		StreamOptions: &openAIStreamOptions{
+			IncludeUsage: true,
+		},
+	}
+	switch {
+	case chatReq.Schema != nil:
+		reqBody.ResponseFormat = &openAIResponseFormat{
+			Type: "json_schema",
+			JSONSchema: &openAIJSONSchema{
+				Name:   chatReq.Schema.Name,
+				Schema: chatReq.Schema.Schema,
+				Strict: true,
+			},
+		}
+	case chatReq.JSONMode:
+		reqBody.ResponseFormat = &openAIResponseFormat{
+			Type: "json_object",
+		}
+	}
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(o.BaseURL, "/")+"/chat/completions", strings.NewReader(string(body)))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if o.ApiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.ApiKey)
+	}
+
+	res, err := o.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, newAPIError(res)
+	}
+
+	var result strings.Builder
+	var usage *Usage
+	reader := bufio.NewReader(res.Body)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		lineStr := string(line)
+		if strings.TrimSpace(lineStr) == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(lineStr, "data: ") {
+			continue
+		}
+		jsonData := strings.TrimPrefix(strings.TrimSpace(lineStr), "data: ")
+
+		if jsonData == "[DONE]" {
+			continue
+		}
+
+		var stream openAIResponse
+		if err := json.Unmarshal([]byte(jsonData), &stream); err != nil {
+			continue
+		}
+
+		if stream.Usage != nil {
+			usage = &Usage{
+				PromptTokens:     stream.Usage.PromptTokens,
+				CompletionTokens: stream.Usage.CompletionTokens,
+				CachedTokens:     stream.Usage.PromptTokensDetails.CachedTokens,
+			}
+		}
+
+		if len(stream.Choices) > 0 {
+			content := stream.Choices[0].Delta.Content
+			if content != "" {
+				result.WriteString(content)
+				if chatReq.OnDelta != nil {
+					chatReq.OnDelta(content)
+				}
+			}
+		}
+	}
+
+	return &ChatResponse{
+		Content: result.String(),
+		Usage:   usage,
+	}, nil
+}
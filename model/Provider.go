@@ -0,0 +1,84 @@
+package model
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// 串流回呼：每收到一段增量內容即呼叫一次
+type StreamFunc func(delta string)
+
+type ChatRequest struct {
+	Model    string
+	Messages []Message
+	OnDelta  StreamFunc
+	// 要求模型只輸出 JSON，不支援的供應商會忽略此設定
+	JSONMode bool
+	// 指定輸出結構，支援的供應商會強制模型遵守
+	Schema *JSONSchema
+	// 重試前呼叫，attempt 為即將進行的第幾次嘗試
+	OnRetry func(attempt, maxAttempts int, err error, wait time.Duration)
+}
+
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	// 命中快取的 prompt token，已包含在 PromptTokens 內
+	CachedTokens int
+}
+
+func (u *Usage) Add(other *Usage) {
+	if other == nil {
+		return
+	}
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.CachedTokens += other.CachedTokens
+}
+
+func (u *Usage) Total() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+type ChatResponse struct {
+	Content string
+	Usage   *Usage
+}
+
+// 模型供應商介面，Frame 只依賴此介面，方便替換後端或注入測試替身
+type Provider interface {
+	Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error)
+}
+
+// 各模型的上下文長度（token），本地模型可由啟動參數覆寫
+var ContextWindowList = map[string]int{
+	"gpt-4o":                    128000,
+	"gpt-4o-mini":               128000,
+	"claude-sonnet-4-20250514":  200000,
+	"claude-3-5-haiku-20241022": 200000,
+	"gemini-2.5-pro":            1048576,
+	"gemini-2.5-flash":          1048576,
+}
+
+// 未知模型回傳 0，代表不限制
+func GetContextWindow(model string) int {
+	return ContextWindowList[model]
+}
+
+// 串流回覆可能很長，不設整體 Timeout，改由 context 中斷；只限制連線與等待回應標頭的時間
+func newHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 120 * time.Second,
+			IdleConnTimeout:       90 * time.Second,
+		},
+	}
+}
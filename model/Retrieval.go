@@ -0,0 +1,120 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+// 單次檢索的完整紀錄：門檻附近候選的分數明細與最終結果
+type SearchReport struct {
+	Query     string  `json:"query"`
+	Threshold float64 `json:"threshold"`
+	MMRLambda float64 `json:"mmr_lambda"`
+	// 只保留門檻附近的候選，CandidateCount 為裁剪前的總數
+	Candidates     []SearchResult `json:"candidates"`
+	CandidateCount int            `json:"candidate_count"`
+	Results        []SearchResult `json:"results"`
+	// 依 token 預算實際注入與捨棄的記錄 ID
+	Injected []int `json:"injected"`
+	Dropped  []int `json:"dropped"`
+}
+
+func (r *SearchReport) FormatContent() string {
+	if r.Query == "" {
+		return "[grey]No retrieval yet[white]\n"
+	}
+
+	var builder strings.Builder
+
+	builder.WriteString("[yellow]Query[white]\n")
+	builder.WriteString(r.Query + "\n\n")
+
+	injectedList := make(map[int]bool, len(r.Injected))
+	for _, id := range r.Injected {
+		injectedList[id] = true
+	}
+
+	builder.WriteString(fmt.Sprintf("[yellow]Candidates[white] (threshold %.2f", r.Threshold))
+	if r.CandidateCount > len(r.Candidates) {
+		builder.WriteString(fmt.Sprintf(", showing %d of %d", len(r.Candidates), r.CandidateCount))
+	}
+	builder.WriteString(")\n")
+	cutoff := false
+	for _, result := range r.Candidates {
+		if !cutoff && result.Score < r.Threshold {
+			cutoff = true
+			builder.WriteString("[red]── threshold ──[white]\n")
+		}
+		addResultToContent(&builder, result, injectedList[result.Record.ID])
+	}
+	if len(r.Candidates) == 0 {
+		builder.WriteString("[grey]none[white]\n")
+	}
+	builder.WriteString("\n")
+
+	// MMR 重排後的順序，即注入時的優先順序
+	builder.WriteString(fmt.Sprintf("[yellow]Selected[white] (MMR λ %.2f)\n", r.MMRLambda))
+	orderList := make([]string, 0, len(r.Results))
+	for _, result := range r.Results {
+		orderList = append(orderList, fmt.Sprintf("#%d", result.Record.ID))
+	}
+	if len(orderList) == 0 {
+		builder.WriteString("[grey]none[white]\n")
+	} else {
+		builder.WriteString(strings.Join(orderList, " → ") + "\n")
+	}
+	builder.WriteString("\n")
+
+	builder.WriteString("[yellow]Padded[white]\n")
+	padded := false
+	for _, result := range r.Results {
+		if result.Padded {
+			padded = true
+			addResultToContent(&builder, result, injectedList[result.Record.ID])
+		}
+	}
+	if !padded {
+		builder.WriteString("[grey]none[white]\n")
+	}
+	builder.WriteString("\n")
+
+	builder.WriteString("[yellow]Dropped[white] (over token budget)\n")
+	if len(r.Dropped) == 0 {
+		builder.WriteString("[grey]none[white]\n")
+	}
+	for _, id := range r.Dropped {
+		builder.WriteString(fmt.Sprintf("#%d\n", id))
+	}
+
+	return builder.String()
+}
+
+func addResultToContent(builder *strings.Builder, result SearchResult, injected bool) {
+	mark := " "
+	if injected {
+		mark = "[green]✓[white]"
+	}
+
+	content := []rune(strings.ReplaceAll(result.Record.Content, "\n", " "))
+	if len(content) > 40 {
+		content = append(content[:40], '…')
+	}
+
+	builder.WriteString(fmt.Sprintf("%s #%d %.3f = K %.2f · S %.2f · T %.2f (x%.2f)\n",
+		mark, result.Record.ID, result.Score, result.Keyword, result.Semantic, result.Time, result.Strength))
+	if len(result.Group) > 1 {
+		idList := make([]string, 0, len(result.Group)-1)
+		for _, record := range result.Group {
+			if record.ID != result.Record.ID {
+				idList = append(idList, fmt.Sprintf("#%d", record.ID))
+			}
+		}
+		builder.WriteString("  [grey]with " + strings.Join(idList, ", ") + "[white]\n")
+	}
+	if len(result.MatchedTerms) > 0 {
+		builder.WriteString("  [grey]terms: " + strings.Join(result.MatchedTerms, ", ") + "[white]\n")
+	}
+	builder.WriteString("  " + tview.Escape(string(content)) + "\n")
+}
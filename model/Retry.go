@@ -0,0 +1,206 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type APIErrorKind int
+
+const (
+	ErrUnknown APIErrorKind = iota
+	ErrRateLimit
+	ErrAuth
+	ErrContextLength
+	ErrServer
+)
+
+func (k APIErrorKind) String() string {
+	switch k {
+	case ErrRateLimit:
+		return "rate limit"
+	case ErrAuth:
+		return "auth"
+	case ErrContextLength:
+		return "context length exceeded"
+	case ErrServer:
+		return "server"
+	default:
+		return "api"
+	}
+}
+
+type APIError struct {
+	Kind       APIErrorKind
+	StatusCode int
+	Message    string
+	// 伺服器建議的等待時間，來自 Retry-After 或 x-ratelimit-reset-*
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("API Error (%s): %s", e.Kind, e.Message)
+	}
+	return fmt.Sprintf("API Error (%s, Status %d): %s", e.Kind, e.StatusCode, e.Message)
+}
+
+// 只有限流與伺服器錯誤值得重試，驗證或上下文過長重試也不會成功
+func (e *APIError) Retryable() bool {
+	return e.Kind == ErrRateLimit || e.Kind == ErrServer
+}
+
+// 讀取非 200 回應並分類
+func newAPIError(res *http.Response) *APIError {
+	bodyBytes, _ := io.ReadAll(res.Body)
+	body := string(bodyBytes)
+
+	apiErr := &APIError{
+		Kind:       classifyStatus(res.StatusCode, body),
+		StatusCode: res.StatusCode,
+		Message:    body,
+		RetryAfter: parseRetryAfter(res.Header),
+	}
+	return apiErr
+}
+
+func classifyStatus(status int, body string) APIErrorKind {
+	lower := strings.ToLower(body)
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrAuth
+	case status == http.StatusTooManyRequests:
+		return ErrRateLimit
+	case strings.Contains(lower, "context_length_exceeded"),
+		strings.Contains(lower, "maximum context length"),
+		strings.Contains(lower, "prompt is too long"),
+		strings.Contains(lower, "exceeds the maximum number of tokens"):
+		return ErrContextLength
+	case status >= 500:
+		// 529 為 Anthropic 的 overloaded
+		return ErrServer
+	default:
+		return ErrUnknown
+	}
+}
+
+func parseRetryAfter(header http.Header) time.Duration {
+	if value := header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+			return time.Duration(seconds * float64(time.Second))
+		}
+		if at, err := http.ParseTime(value); err == nil {
+			return time.Until(at)
+		}
+	}
+
+	// OpenAI 以 x-ratelimit-remaining-* 與 x-ratelimit-reset-*（如 "6m0s"、"20ms"）標示額度
+	var wait time.Duration
+	for _, kind := range []string{"requests", "tokens"} {
+		if header.Get("x-ratelimit-remaining-"+kind) != "0" {
+			continue
+		}
+		reset, err := time.ParseDuration(header.Get("x-ratelimit-reset-" + kind))
+		if err == nil && reset > wait {
+			wait = reset
+		}
+	}
+	return wait
+}
+
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   time.Second,
+		MaxDelay:    30 * time.Second,
+	}
+}
+
+// 指數退避加上 full jitter，伺服器有指定時以伺服器為準（超過 MaxDelay 時由呼叫端放棄重試）
+func (p RetryPolicy) delay(attempt int, err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return apiErr.RetryAfter
+	}
+
+	backoff := p.BaseDelay << (attempt - 1)
+	if backoff <= 0 || backoff > p.MaxDelay {
+		backoff = p.MaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(backoff) + 1))
+}
+
+type retryProvider struct {
+	Provider
+	policy RetryPolicy
+}
+
+// 包裝任意 Provider，遇到可重試錯誤時自動重送
+func WithRetry(provider Provider, policy RetryPolicy) Provider {
+	return &retryProvider{
+		Provider: provider,
+		policy:   policy,
+	}
+}
+
+func (r *retryProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	// 已經串流出內容就不能重送，否則畫面會重複
+	streamed := false
+	onDelta := req.OnDelta
+	req.OnDelta = func(delta string) {
+		streamed = true
+		if onDelta != nil {
+			onDelta(delta)
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		res, err := r.Provider.Chat(ctx, req)
+		if err == nil || attempt >= r.policy.MaxAttempts || streamed || !shouldRetry(ctx, err) {
+			return res, err
+		}
+
+		// 伺服器要求的等待超過上限時不重試，直接回報需等待的時間，避免回合被卡住
+		wait := r.policy.delay(attempt, err)
+		if wait > r.policy.MaxDelay {
+			return nil, fmt.Errorf("%w (server asked to retry after %s)", err, wait.Round(time.Second))
+		}
+		if req.OnRetry != nil {
+			req.OnRetry(attempt+1, r.policy.MaxAttempts, err, wait)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func shouldRetry(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable()
+	}
+	// 連線中斷等網路錯誤視為暫時性
+	return true
+}
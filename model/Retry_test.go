@@ -0,0 +1,206 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{"seconds", http.Header{"Retry-After": {"2"}}, 2 * time.Second},
+		{"fraction", http.Header{"Retry-After": {"0.5"}}, 500 * time.Millisecond},
+		{"ratelimit reset", http.Header{
+			"X-Ratelimit-Remaining-Requests": {"0"},
+			"X-Ratelimit-Reset-Requests":     {"1s"},
+			"X-Ratelimit-Remaining-Tokens":   {"0"},
+			"X-Ratelimit-Reset-Tokens":       {"6m0s"},
+		}, 6 * time.Minute},
+		// 額度未用完時不等待
+		{"ratelimit remaining", http.Header{
+			"X-Ratelimit-Remaining-Requests": {"3"},
+			"X-Ratelimit-Reset-Requests":     {"20ms"},
+		}, 0},
+		{"none", http.Header{}, 0},
+		{"invalid", http.Header{"Retry-After": {"soon"}}, 0},
+	} {
+		if got := parseRetryAfter(test.header); got != test.want {
+			t.Errorf("%s: parseRetryAfter = %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	// HTTP 日期格式以距離現在的時間計算
+	at := time.Now().Add(90 * time.Second).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(http.Header{"Retry-After": {at}}); got < 80*time.Second || got > 90*time.Second {
+		t.Errorf("http date: parseRetryAfter = %v, want about 90s", got)
+	}
+}
+
+func TestClassifyStatus(t *testing.T) {
+	for _, test := range []struct {
+		status int
+		body   string
+		want   APIErrorKind
+	}{
+		{http.StatusUnauthorized, "", ErrAuth},
+		{http.StatusForbidden, "", ErrAuth},
+		{http.StatusTooManyRequests, "", ErrRateLimit},
+		{http.StatusBadRequest, `{"error": {"code": "context_length_exceeded"}}`, ErrContextLength},
+		{http.StatusBadRequest, "prompt is too long: 210000 tokens > 200000 maximum", ErrContextLength},
+		{http.StatusBadRequest, "The input token count exceeds the maximum number of tokens allowed", ErrContextLength},
+		{http.StatusBadRequest, "invalid model", ErrUnknown},
+		{http.StatusInternalServerError, "", ErrServer},
+		{529, "overloaded", ErrServer},
+	} {
+		if got := classifyStatus(test.status, test.body); got != test.want {
+			t.Errorf("classifyStatus(%d, %q) = %v, want %v", test.status, test.body, got, test.want)
+		}
+	}
+}
+
+func TestNewAPIError(t *testing.T) {
+	apiErr := newAPIError(&http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"Retry-After": {"3"}},
+		Body:       io.NopCloser(strings.NewReader("slow down")),
+	})
+	if apiErr.Kind != ErrRateLimit || apiErr.RetryAfter != 3*time.Second || apiErr.Message != "slow down" || !apiErr.Retryable() {
+		t.Errorf("apiErr = %+v", apiErr)
+	}
+}
+
+// 依序回傳預先設定的結果，並記錄呼叫次數
+type fakeProvider struct {
+	calls  int
+	deltas []string
+	errs   []error
+}
+
+func (p *fakeProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	p.calls++
+	if p.calls <= len(p.deltas) && p.deltas[p.calls-1] != "" && req.OnDelta != nil {
+		req.OnDelta(p.deltas[p.calls-1])
+	}
+	if p.calls <= len(p.errs) && p.errs[p.calls-1] != nil {
+		return nil, p.errs[p.calls-1]
+	}
+	return &ChatResponse{Content: "ok"}, nil
+}
+
+func testRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    10 * time.Millisecond,
+	}
+}
+
+func TestRetryProvider(t *testing.T) {
+	serverErr := &APIError{Kind: ErrServer, StatusCode: 500, Message: "boom"}
+	authErr := &APIError{Kind: ErrAuth, StatusCode: 401, Message: "bad key"}
+	networkErr := errors.New("connection reset")
+
+	for _, test := range []struct {
+		name      string
+		provider  *fakeProvider
+		wantCalls int
+		wantErr   error
+		wantRetry int
+	}{
+		{"success", &fakeProvider{}, 1, nil, 0},
+		{"retry server error", &fakeProvider{errs: []error{serverErr, serverErr}}, 3, nil, 2},
+		{"retry network error", &fakeProvider{errs: []error{networkErr}}, 2, nil, 1},
+		{"no retry on auth", &fakeProvider{errs: []error{authErr}}, 1, authErr, 0},
+		{"give up after max attempts", &fakeProvider{errs: []error{serverErr, serverErr, serverErr, serverErr}}, 3, serverErr, 2},
+		// 已串流出內容時重送會讓畫面重複
+		{"no retry after streaming", &fakeProvider{deltas: []string{"partial"}, errs: []error{serverErr}}, 1, serverErr, 0},
+	} {
+		retryCount := 0
+		response, err := WithRetry(test.provider, testRetryPolicy()).Chat(context.Background(), ChatRequest{
+			OnDelta: func(string) {},
+			OnRetry: func(attempt, maxAttempts int, err error, wait time.Duration) {
+				retryCount++
+				if attempt != retryCount+1 || maxAttempts != 3 || wait > 10*time.Millisecond {
+					t.Errorf("%s: OnRetry(%d, %d, %v, %v)", test.name, attempt, maxAttempts, err, wait)
+				}
+			},
+		})
+
+		if test.provider.calls != test.wantCalls {
+			t.Errorf("%s: calls = %d, want %d", test.name, test.provider.calls, test.wantCalls)
+		}
+		if retryCount != test.wantRetry {
+			t.Errorf("%s: retries = %d, want %d", test.name, retryCount, test.wantRetry)
+		}
+		if !errors.Is(err, test.wantErr) {
+			t.Errorf("%s: err = %v, want %v", test.name, err, test.wantErr)
+		}
+		if test.wantErr == nil && (response == nil || response.Content != "ok") {
+			t.Errorf("%s: response = %+v", test.name, response)
+		}
+	}
+}
+
+// 伺服器要求的等待超過 MaxDelay 時不重試，錯誤中附上需等待的時間
+func TestRetryProviderLongRetryAfter(t *testing.T) {
+	rateErr := &APIError{Kind: ErrRateLimit, StatusCode: 429, Message: "slow down", RetryAfter: time.Hour}
+	provider := &fakeProvider{errs: []error{rateErr}}
+
+	start := time.Now()
+	_, err := WithRetry(provider, testRetryPolicy()).Chat(context.Background(), ChatRequest{})
+	if time.Since(start) > time.Second {
+		t.Errorf("waited %v", time.Since(start))
+	}
+	if provider.calls != 1 {
+		t.Errorf("calls = %d, want 1", provider.calls)
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr != rateErr {
+		t.Errorf("err = %v, want the rate limit error", err)
+	}
+	if !strings.Contains(err.Error(), "retry after 1h0m0s") {
+		t.Errorf("err = %q, want the requested wait", err)
+	}
+}
+
+// 等待重試期間中斷時立即結束，不再呼叫
+func TestRetryProviderCancel(t *testing.T) {
+	serverErr := &APIError{Kind: ErrServer, StatusCode: 503, Message: "unavailable", RetryAfter: 5 * time.Second}
+	provider := &fakeProvider{errs: []error{serverErr, serverErr}}
+	policy := testRetryPolicy()
+	policy.MaxDelay = time.Minute
+
+	ctx, cancel := context.WithCancel(context.Background())
+	start := time.Now()
+	_, err := WithRetry(provider, policy).Chat(ctx, ChatRequest{
+		OnRetry: func(int, int, error, time.Duration) {
+			cancel()
+		},
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if provider.calls != 1 {
+		t.Errorf("calls = %d, want 1", provider.calls)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("waited %v after cancel", time.Since(start))
+	}
+
+	// 已中斷的 context 不重試
+	provider = &fakeProvider{errs: []error{serverErr}}
+	_, err = WithRetry(provider, policy).Chat(ctx, ChatRequest{})
+	if provider.calls != 1 || !errors.Is(err, serverErr) {
+		t.Errorf("calls = %d, err = %v, want a single call", provider.calls, err)
+	}
+}
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"reflect"
+	"strings"
+)
+
+type JSONSchema struct {
+	Name   string
+	Schema map[string]any
+}
+
+// 依 struct 的 json tag 產生 JSON Schema，所有欄位皆為必填且不允許額外欄位（符合 OpenAI strict 模式）
+func SchemaOf(v any) map[string]any {
+	return schemaOfType(reflect.TypeOf(v))
+}
+
+func schemaOfType(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{
+			"type":  "array",
+			"items": schemaOfType(t.Elem()),
+		}
+	case reflect.Struct:
+		properties := map[string]any{}
+		required := make([]string, 0, t.NumField())
+
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			name := field.Name
+			if tag := field.Tag.Get("json"); tag != "" {
+				tagName, _, _ := strings.Cut(tag, ",")
+				if tagName == "-" {
+					continue
+				}
+				if tagName != "" {
+					name = tagName
+				}
+			}
+
+			properties[name] = schemaOfType(field.Type)
+			required = append(required, name)
+		}
+
+		return map[string]any{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	default:
+		return map[string]any{}
+	}
+}
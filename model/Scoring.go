@@ -0,0 +1,172 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+type DecayKind string
+
+const (
+	// 在 DecayHours 內線性下降到 DecayFloor
+	DecayLinear DecayKind = "linear"
+	// 每經過 HalfLifeHours 剩餘分數減半
+	DecayExponential DecayKind = "exponential"
+	// Ebbinghaus 原始實驗擬合：b = 1.84 / ((log10 t)^1.25 + 1.84)，t 以分鐘計
+	// 前幾十分鐘下降最快，之後趨緩，較接近人類遺忘
+	DecayEbbinghaus DecayKind = "ebbinghaus"
+)
+
+type RecallMode string
+
+const (
+	// 只注入命中的單筆記錄
+	RecallRecord RecallMode = "record"
+	// 注入命中記錄所屬的整個回合（提問與回覆）
+	RecallTurn RecallMode = "turn"
+	// 注入命中記錄前後各 RecallWindow 筆
+	RecallWindow RecallMode = "window"
+)
+
+type ScoringConfig struct {
+	Threshold      float64   `json:"threshold"`
+	KeywordWeight  float64   `json:"keyword_weight"`
+	SemanticWeight float64   `json:"semantic_weight"`
+	TimeWeight     float64   `json:"time_weight"`
+	Decay          DecayKind `json:"decay"`
+	// 時間分數的下限，長期記憶不會完全遺忘
+	DecayFloor    float64 `json:"decay_floor"`
+	DecayHours    float64 `json:"decay_hours"`
+	HalfLifeHours float64 `json:"half_life_hours"`
+	// 每次被想起時記憶強度的最大增幅，0 代表不強化
+	ReinforceGain float64 `json:"reinforce_gain"`
+	// 命中記錄時一併注入的上下文範圍
+	Recall       RecallMode `json:"recall"`
+	RecallWindow int        `json:"recall_window"`
+	// MMR 重排的 λ：1 只看相關度，越小越偏好與已選記錄不同的內容
+	MMRLambda float64 `json:"mmr_lambda"`
+}
+
+// 預設值與原本固定的 0.4 / 0.4 / 0.2 與 24 小時線性衰減相同
+func DefaultScoringConfig() ScoringConfig {
+	return ScoringConfig{
+		Threshold:      0.3,
+		KeywordWeight:  0.4,
+		SemanticWeight: 0.4,
+		TimeWeight:     0.2,
+		Decay:          DecayLinear,
+		DecayFloor:     0.7,
+		DecayHours:     24,
+		HalfLifeHours:  24,
+		ReinforceGain:  1.0,
+		Recall:         RecallTurn,
+		RecallWindow:   1,
+		MMRLambda:      0.7,
+	}
+}
+
+// 未填寫的欄位沿用預設值，拼錯的欄位名稱視為錯誤，避免設定悄悄失效
+func ParseScoringConfig(data []byte) (ScoringConfig, error) {
+	config := DefaultScoringConfig()
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return config, fmt.Errorf("invalid scoring config: %w", err)
+	}
+	if decoder.More() {
+		return config, fmt.Errorf("invalid scoring config: unexpected data after the JSON object")
+	}
+	if err := config.Validate(); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+func (c ScoringConfig) Validate() error {
+	for name, value := range map[string]float64{
+		"threshold":       c.Threshold,
+		"keyword_weight":  c.KeywordWeight,
+		"semantic_weight": c.SemanticWeight,
+		"time_weight":     c.TimeWeight,
+		"decay_floor":     c.DecayFloor,
+		"mmr_lambda":      c.MMRLambda,
+	} {
+		if value < 0 || value > 1 {
+			return fmt.Errorf("invalid scoring config: %s must be between 0 and 1, got %v", name, value)
+		}
+	}
+
+	if sum := c.KeywordWeight + c.SemanticWeight + c.TimeWeight; math.Abs(sum-1) > 1e-6 {
+		return fmt.Errorf("invalid scoring config: weights must sum to 1, got %v", sum)
+	}
+
+	if c.ReinforceGain < 0 {
+		return fmt.Errorf("invalid scoring config: reinforce_gain must not be negative")
+	}
+
+	switch c.Recall {
+	case RecallRecord, RecallTurn:
+	case RecallWindow:
+		if c.RecallWindow < 0 {
+			return fmt.Errorf("invalid scoring config: recall_window must not be negative")
+		}
+	default:
+		return fmt.Errorf("invalid scoring config: unknown recall %q (record, turn, window)", c.Recall)
+	}
+
+	switch c.Decay {
+	case DecayLinear:
+		if c.DecayHours <= 0 {
+			return fmt.Errorf("invalid scoring config: decay_hours must be positive")
+		}
+	case DecayExponential:
+		if c.HalfLifeHours <= 0 {
+			return fmt.Errorf("invalid scoring config: half_life_hours must be positive")
+		}
+	case DecayEbbinghaus:
+	default:
+		return fmt.Errorf("invalid scoring config: unknown decay %q (linear, exponential, ebbinghaus)", c.Decay)
+	}
+	return nil
+}
+
+// 回傳 DecayFloor 到 1.0 之間的時間分數
+func (c ScoringConfig) timeScore(elapsed time.Duration) float64 {
+	hours := math.Max(elapsed.Hours(), 0)
+
+	var retention float64
+	switch c.Decay {
+	case DecayExponential:
+		retention = math.Pow(0.5, hours/c.HalfLifeHours)
+	case DecayEbbinghaus:
+		minutes := hours * 60
+		if minutes <= 1 {
+			retention = 1.0
+		} else {
+			retention = 1.84 / (math.Pow(math.Log10(minutes), 1.25) + 1.84)
+		}
+	default:
+		retention = math.Max(1-hours/c.DecayHours, 0)
+	}
+
+	return c.DecayFloor + (1-c.DecayFloor)*retention
+}
+
+type ComparerOption func(*Comparer)
+
+// 會一併覆寫 NewFuzzyComparer 傳入的 threshold
+func WithScoringConfig(config ScoringConfig) ComparerOption {
+	return func(f *Comparer) {
+		f.config = config
+	}
+}
+
+func WithEmbedder(embedder Embedder) ComparerOption {
+	return func(f *Comparer) {
+		f.embedder = embedder
+		f.vectorIndex = NewVectorIndex()
+	}
+}
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseScoringConfig(t *testing.T) {
+	config, err := ParseScoringConfig([]byte(`{"threshold": 0.25, "decay": "exponential", "recall": "window", "recall_window": 2}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := DefaultScoringConfig()
+	want.Threshold = 0.25
+	want.Decay = DecayExponential
+	want.Recall = RecallWindow
+	want.RecallWindow = 2
+	if config != want {
+		t.Errorf("config = %+v, want %+v", config, want)
+	}
+}
+
+func TestParseScoringConfigInvalid(t *testing.T) {
+	for _, test := range []struct {
+		data string
+		want string
+	}{
+		{`{"treshold": 0.25}`, `unknown field "treshold"`},
+		{`{"threshold": 0.25} {"threshold": 0.5}`, "unexpected data"},
+		{`{"threshold": "high"}`, "invalid scoring config"},
+		{`{"mmr_lambda": 1.5}`, "mmr_lambda"},
+	} {
+		_, err := ParseScoringConfig([]byte(test.data))
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("ParseScoringConfig(%s) error = %v, want %q", test.data, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 每個對話一個目錄：session.json 保存紀錄、概要與記憶，vectors.gob 保存向量索引
+const (
+	sessionFile = "session.json"
+	vectorFile  = "vectors.gob"
+)
+
+type Session struct {
+	Name            string                `json:"name"`
+	CreatedAt       time.Time             `json:"created_at"`
+	UpdatedAt       time.Time             `json:"updated_at"`
+	ConversationLog string                `json:"conversation_log"`
+	Summary         Summary               `json:"summary"`
+	SummaryHistory  []SummaryVersion      `json:"summary_history,omitempty"`
+	UserItems       map[string][]string   `json:"user_items,omitempty"`
+	Records         []*ConversationRecord `json:"records"`
+	Usage           Usage                 `json:"usage"`
+	ChatCost        float64               `json:"chat_cost"`
+	SummaryCost     float64               `json:"summary_cost"`
+	// 產生向量的 Embedder，不同時向量索引無法沿用，需重新計算
+	Embedder string `json:"embedder"`
+	// 分支來源的對話名稱
+	Parent string `json:"parent,omitempty"`
+}
+
+// 對話列表顯示用的摘要資訊
+type SessionInfo struct {
+	Name           string    `json:"name"`
+	Parent         string    `json:"parent"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	Turns          int       `json:"turns"`
+	CoreDiscussion string    `json:"core_discussion"`
+}
+
+type SessionStore struct {
+	Dir string
+}
+
+func NewSessionStore(dir string) *SessionStore {
+	return &SessionStore{Dir: dir}
+}
+
+// 名稱直接作為目錄名稱，不允許路徑字元
+func validateSessionName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid session name %q", name)
+	}
+	return nil
+}
+
+func (s *SessionStore) path(name string) string {
+	return filepath.Join(s.Dir, name)
+}
+
+func (s *SessionStore) VectorPath(name string) string {
+	return filepath.Join(s.path(name), vectorFile)
+}
+
+// 不存在時回傳 os.ErrNotExist
+func (s *SessionStore) Load(name string) (*Session, error) {
+	if err := validateSessionName(name); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.path(name), sessionFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("invalid session %q: %w", name, err)
+	}
+	session.Name = name
+	return &session, nil
+}
+
+func (s *SessionStore) Save(session *Session) error {
+	if err := validateSessionName(session.Name); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.path(session.Name), 0o755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.path(session.Name), sessionFile), data)
+}
+
+// 依最後活動時間排序，無法讀取的目錄略過
+func (s *SessionStore) List() ([]SessionInfo, error) {
+	entryList, err := os.ReadDir(s.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	infoList := make([]SessionInfo, 0, len(entryList))
+	for _, entry := range entryList {
+		if !entry.IsDir() {
+			continue
+		}
+		session, err := s.Load(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		turns := 0
+		for _, record := range session.Records {
+			turns = max(turns, record.TurnID)
+		}
+		infoList = append(infoList, SessionInfo{
+			Name:           session.Name,
+			Parent:         session.Parent,
+			UpdatedAt:      session.UpdatedAt,
+			Turns:          turns,
+			CoreDiscussion: session.Summary.CoreDiscussion,
+		})
+	}
+
+	sort.Slice(infoList, func(i, j int) bool {
+		return infoList[i].UpdatedAt.After(infoList[j].UpdatedAt)
+	})
+	return infoList, nil
+}
+
+func (s *SessionStore) Exists(name string) bool {
+	_, err := os.Stat(filepath.Join(s.path(name), sessionFile))
+	return err == nil
+}
+
+func (s *SessionStore) Rename(name, newName string) error {
+	if err := validateSessionName(name); err != nil {
+		return err
+	}
+	if err := validateSessionName(newName); err != nil {
+		return err
+	}
+	if _, err := os.Stat(s.path(newName)); err == nil {
+		return fmt.Errorf("session %q already exists", newName)
+	}
+	return os.Rename(s.path(name), s.path(newName))
+}
+
+func (s *SessionStore) Delete(name string) error {
+	if err := validateSessionName(name); err != nil {
+		return err
+	}
+	return os.RemoveAll(s.path(name))
+}
+
+// 複製概要、記憶與向量索引成為新的分支，之後兩邊各自發展
+func (s *SessionStore) Fork(name, newName string) error {
+	if err := validateSessionName(newName); err != nil {
+		return err
+	}
+	if _, err := os.Stat(s.path(newName)); err == nil {
+		return fmt.Errorf("session %q already exists", newName)
+	}
+
+	session, err := s.Load(name)
+	if err != nil {
+		return err
+	}
+	session.Name = newName
+	session.Parent = name
+	session.CreatedAt = time.Now()
+	if err := s.Save(session); err != nil {
+		return err
+	}
+
+	// 向量索引不存在時還原後會重新計算
+	data, err := os.ReadFile(s.VectorPath(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.VectorPath(newName), data)
+}
+
+// 先寫入暫存檔再改名，中途結束也不會留下寫到一半的檔案
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// 開啟對話，不存在時建立新的空白對話，第一輪結束後才寫入
+// 需先設定 Sessions 與 EmbedderID
+func (f *Frame) OpenSession(name string) error {
+	if err := validateSessionName(name); err != nil {
+		return err
+	}
+
+	session, err := f.Sessions.Load(name)
+	if errors.Is(err, os.ErrNotExist) {
+		session = &Session{
+			Name:      name,
+			CreatedAt: time.Now(),
+		}
+	} else if err != nil {
+		return err
+	}
+
+	f.SessionName = name
+	f.sessionParent = session.Parent
+	f.sessionCreatedAt = session.CreatedAt
+	f.sessionRecords = nil
+	f.sessionEmbedder = f.EmbedderID
+	if session.UpdatedAt.IsZero() {
+		f.updateRecordTitle()
+		return nil
+	}
+
+	f.restoreSession(session)
+	return nil
+}
+
+func (f *Frame) restoreSession(session *Session) {
+	f.CurrentSummary = session.Summary
+	f.resetSummaryHistory(session.SummaryHistory)
+	f.UserItems = session.UserItems
+	f.SessionUsage = session.Usage
+	f.ChatCost = session.ChatCost
+	f.SummaryCost = session.SummaryCost
+
+	f.conversationLog.Reset()
+	f.conversationLog.WriteString(session.ConversationLog)
+
+	if f.Comparer == nil {
+		f.sessionRecords = session.Records
+		f.sessionEmbedder = session.Embedder
+	} else {
+		f.Comparer.Restore(session.Records)
+
+		// Embedder 相同時沿用向量索引，再補算索引中沒有的記錄
+		// 上次保存前尚未算完向量的記錄不在索引內，讀取失敗時則全部重新計算
+		if session.Embedder == f.EmbedderID {
+			f.Comparer.LoadVectorIndex(f.Sessions.VectorPath(session.Name))
+		}
+		f.Comparer.EmbedMissing()
+	}
+
+	if f.Summary != nil {
+		f.updateSummary()
+	}
+	f.AddToConversation(false, fmt.Sprintf("[grey]%v[white]", "Session"), fmt.Sprintf("[grey]resumed %s (%d records, last active %s)[white]",
+		session.Name,
+		len(session.Records),
+		session.UpdatedAt.Format("2006-01-02 15:04:05"),
+	))
+	f.updateRecordTitle()
+}
+
+// 每輪結束後寫入，需在 UI goroutine 呼叫，失敗時只顯示錯誤
+func (f *Frame) saveSession() {
+	if f.Sessions == nil || f.SessionName == "" {
+		return
+	}
+
+	session := &Session{
+		Name:            f.SessionName,
+		CreatedAt:       f.sessionCreatedAt,
+		UpdatedAt:       time.Now(),
+		ConversationLog: f.conversationLog.String(),
+		Summary:         f.CurrentSummary,
+		SummaryHistory:  f.SummaryHistory,
+		UserItems:       f.UserItems,
+		Usage:           f.SessionUsage,
+		ChatCost:        f.ChatCost,
+		SummaryCost:     f.SummaryCost,
+		Records:         f.sessionRecords,
+		Embedder:        f.sessionEmbedder,
+		Parent:          f.sessionParent,
+	}
+
+	err := func() error {
+		if f.Comparer != nil {
+			session.Records = f.Comparer.Records()
+			session.Embedder = f.EmbedderID
+		}
+		if err := f.Sessions.Save(session); err != nil {
+			return err
+		}
+		if f.Comparer != nil {
+			return f.Comparer.SaveVectorIndex(f.Sessions.VectorPath(session.Name))
+		}
+		return nil
+	}()
+	if err != nil {
+		f.AddToConversation(true, fmt.Sprintf("[red]%v[white]", "Error"), fmt.Sprintf("[red]failed to save session: %v[white]", err))
+	}
+}
@@ -0,0 +1,391 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const (
+	sessionPage = "sessions"
+	promptPage  = "prompt"
+	confirmPage = "confirm"
+)
+
+// 置中顯示的浮動視窗
+func centered(primitive tview.Primitive, width, height int) tview.Primitive {
+	return tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(primitive, height, 1, true).
+			AddItem(nil, 0, 1, false), width, 1, true).
+		AddItem(nil, 0, 1, false)
+}
+
+// 對話列表是否開啟，開啟時不處理主畫面的焦點切換
+func (f *Frame) SessionBrowserVisible() bool {
+	if f.pages == nil {
+		return false
+	}
+	name, _ := f.pages.GetFrontPage()
+	return name != "main"
+}
+
+// 切換對話列表：Enter 開啟、n 新增、r 重新命名、f 分支、d 刪除、Esc 關閉
+func (f *Frame) ToggleSessionBrowser() {
+	if f.pages == nil || f.Sessions == nil {
+		return
+	}
+
+	if f.pages.HasPage(sessionPage) {
+		f.closeSessionBrowser()
+		return
+	}
+
+	list := tview.NewList().
+		ShowSecondaryText(true).
+		SetSecondaryTextColor(tcell.ColorGray)
+	list.
+		SetBorder(true).
+		SetTitle(" Sessions · Enter open · n new · r rename · f fork · d delete · Esc close ").
+		SetTitleAlign(tview.AlignLeft)
+
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			f.closeSessionBrowser()
+			return nil
+		}
+
+		switch event.Rune() {
+		case 'n':
+			f.confirmUnsaved(func() {
+				f.promptSessionName("New session", "", func(newName string) error {
+					if err := f.switchSession(newName, true); err != nil {
+						return err
+					}
+					f.closeSessionBrowser()
+					return nil
+				})
+			})
+			return nil
+		case 'r':
+			if name := f.selectedSession(); name != "" {
+				f.promptSessionName("Rename "+name, name, func(newName string) error {
+					return f.renameSession(name, newName)
+				})
+			}
+			return nil
+		case 'f':
+			if name := f.selectedSession(); name != "" {
+				f.confirmUnsaved(func() {
+					f.promptSessionName("Fork "+name, name+"-fork", func(newName string) error {
+						return f.forkSession(name, newName)
+					})
+				})
+			}
+			return nil
+		case 'd':
+			if name := f.selectedSession(); name != "" {
+				f.confirmDeleteSession(name)
+			}
+			return nil
+		}
+		return event
+	})
+
+	f.sessionList = list
+	f.refreshSessionList()
+	list.SetSelectedFunc(func(int, string, string, rune) {
+		if name := f.selectedSession(); name != "" {
+			f.confirmUnsaved(func() {
+				f.runSessionAction(func() error {
+					return f.switchSession(name, false)
+				})
+			})
+		}
+	})
+
+	f.pages.AddPage(sessionPage, centered(list, 90, 24), true, true)
+	f.App.SetFocus(list)
+}
+
+func (f *Frame) closeSessionBrowser() {
+	f.pages.RemovePage(promptPage)
+	f.pages.RemovePage(confirmPage)
+	f.pages.RemovePage(sessionPage)
+	f.sessionList = nil
+	f.sessionNames = nil
+	f.App.SetFocus(f.Input)
+}
+
+// 列表項目依序對應 Sessions.List 的結果，名稱另外保存供選取時使用
+func (f *Frame) refreshSessionList() {
+	list := f.sessionList
+	if list == nil {
+		return
+	}
+
+	current := list.GetCurrentItem()
+	list.Clear()
+	f.sessionNames = nil
+
+	infoList, err := f.Sessions.List()
+	if err != nil {
+		list.AddItem(fmt.Sprintf("[red]%v[white]", err), "", 0, nil)
+		return
+	}
+	if len(infoList) == 0 {
+		list.AddItem("[grey]no saved sessions, press n to create one[white]", "", 0, nil)
+		return
+	}
+
+	for _, info := range infoList {
+		mark := "  "
+		if info.Name == f.SessionName {
+			mark = "[green]●[white] "
+		}
+		title := fmt.Sprintf("%s%s  [grey]%s · %d turns[white]", mark, tview.Escape(info.Name), info.UpdatedAt.Format("2006-01-02 15:04"), info.Turns)
+		if info.Parent != "" {
+			title += fmt.Sprintf(" [grey]· fork of %s[white]", tview.Escape(info.Parent))
+		}
+		list.AddItem(title, "  "+tview.Escape(info.CoreDiscussion), 0, nil)
+		f.sessionNames = append(f.sessionNames, info.Name)
+	}
+	list.SetCurrentItem(min(current, list.GetItemCount()-1))
+}
+
+// 目前選取的對話名稱，列表為空時回傳空字串
+// 依上次刷新時的名稱對應，不重新讀取目錄，避免順序在游標下改變
+func (f *Frame) selectedSession() string {
+	if f.sessionList == nil {
+		return ""
+	}
+
+	index := f.sessionList.GetCurrentItem()
+	if index < 0 || index >= len(f.sessionNames) {
+		return ""
+	}
+	return f.sessionNames[index]
+}
+
+// 輸入對話名稱，確認後執行 action，失敗時保留視窗並顯示錯誤
+func (f *Frame) promptSessionName(title, value string, action func(name string) error) {
+	input := tview.NewInputField().
+		SetLabel("Name: ").
+		SetText(value)
+	input.
+		SetBorder(true).
+		SetTitle(" " + title + " · Enter confirm · Esc cancel ").
+		SetTitleAlign(tview.AlignLeft)
+
+	input.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			f.pages.RemovePage(promptPage)
+			f.App.SetFocus(f.sessionList)
+			return
+		}
+		if key != tcell.KeyEnter {
+			return
+		}
+
+		if err := action(input.GetText()); err != nil {
+			input.SetTitle(fmt.Sprintf(" %s · [red]%v[white] ", title, err))
+			return
+		}
+		f.pages.RemovePage(promptPage)
+		if f.sessionList != nil {
+			f.refreshSessionList()
+			f.App.SetFocus(f.sessionList)
+		}
+	})
+
+	f.pages.AddPage(promptPage, centered(input, 60, 3), true, true)
+	f.App.SetFocus(input)
+}
+
+func (f *Frame) confirmDeleteSession(name string) {
+	if name == f.SessionName {
+		f.sessionList.SetTitle(" Sessions · [red]cannot delete the open session[white] ")
+		return
+	}
+
+	modal := tview.NewModal().
+		SetText(fmt.Sprintf("Delete session %q?\nThis cannot be undone.", name)).
+		AddButtons([]string{"Delete", "Cancel"}).
+		SetDoneFunc(func(_ int, label string) {
+			f.pages.RemovePage(confirmPage)
+			if label == "Delete" {
+				if err := f.Sessions.Delete(name); err != nil {
+					f.sessionList.SetTitle(fmt.Sprintf(" Sessions · [red]%v[white] ", err))
+				}
+				f.refreshSessionList()
+			}
+			f.App.SetFocus(f.sessionList)
+		})
+
+	f.pages.AddPage(confirmPage, modal, true, true)
+	f.App.SetFocus(modal)
+}
+
+// 未以 --session 或列表命名的對話不會自動保存，切換前提示先命名保存或確認捨棄
+// 沒有未保存的內容時直接執行 next
+func (f *Frame) confirmUnsaved(next func()) {
+	if !f.hasUnsavedConversation() {
+		next()
+		return
+	}
+
+	modal := tview.NewModal().
+		SetText("The current conversation has not been saved as a session.\nSave it before switching?").
+		AddButtons([]string{"Save as", "Discard", "Cancel"}).
+		SetDoneFunc(func(_ int, label string) {
+			f.pages.RemovePage(confirmPage)
+			f.App.SetFocus(f.sessionList)
+			switch label {
+			case "Save as":
+				f.promptSessionName("Save current conversation", "", func(name string) error {
+					if err := f.saveUnnamedSession(name); err != nil {
+						return err
+					}
+					// 等命名視窗關閉後再執行，next 可能開啟新的命名視窗
+					// 事件處理中直接排入可能在佇列滿時阻塞，改由 goroutine 排入
+					go f.App.QueueUpdateDraw(next)
+					return nil
+				})
+			case "Discard":
+				next()
+			}
+		})
+
+	f.pages.AddPage(confirmPage, modal, true, true)
+	f.App.SetFocus(modal)
+}
+
+// 尚未命名且已有對話內容，切換時 saveSession 不會寫入
+func (f *Frame) hasUnsavedConversation() bool {
+	if f.SessionName != "" {
+		return false
+	}
+	if f.Comparer != nil && f.Comparer.Len() > 0 {
+		return true
+	}
+	return f.SessionUsage.Total() > 0 || len(f.SummaryHistory) > 1 || len(f.UserItems) > 0
+}
+
+// 以新名稱保存目前尚未命名的對話
+func (f *Frame) saveUnnamedSession(name string) error {
+	if err := validateSessionName(name); err != nil {
+		return err
+	}
+	if f.Sessions.Exists(name) {
+		return fmt.Errorf("session %q already exists", name)
+	}
+
+	f.SessionName = name
+	if f.sessionCreatedAt.IsZero() {
+		f.sessionCreatedAt = time.Now()
+	}
+	f.saveSession()
+	f.updateRecordTitle()
+	return nil
+}
+
+// 執行後關閉列表，失敗時顯示在列表標題
+func (f *Frame) runSessionAction(action func() error) {
+	if err := action(); err != nil {
+		f.sessionList.SetTitle(fmt.Sprintf(" Sessions · [red]%v[white] ", err))
+		return
+	}
+	f.closeSessionBrowser()
+}
+
+// 保存目前對話後切換，create 為 true 時建立新對話且名稱不可重複
+func (f *Frame) switchSession(name string, create bool) error {
+	if f.cancel != nil {
+		return fmt.Errorf("wait for or abort the current response first")
+	}
+	if err := validateSessionName(name); err != nil {
+		return err
+	}
+	if create && f.Sessions.Exists(name) {
+		return fmt.Errorf("session %q already exists", name)
+	}
+
+	f.saveSession()
+	f.resetSession()
+	if err := f.OpenSession(name); err != nil {
+		return err
+	}
+
+	// 新對話立即寫入，才會出現在列表中
+	if create {
+		f.saveSession()
+	}
+	return nil
+}
+
+func (f *Frame) renameSession(name, newName string) error {
+	if name == f.SessionName {
+		if f.cancel != nil {
+			return fmt.Errorf("wait for or abort the current response first")
+		}
+		f.saveSession()
+	}
+
+	if err := f.Sessions.Rename(name, newName); err != nil {
+		return err
+	}
+	if name == f.SessionName {
+		f.SessionName = newName
+		f.updateRecordTitle()
+	}
+	return nil
+}
+
+// 分支後切換到新的對話，原對話維持不變
+func (f *Frame) forkSession(name, newName string) error {
+	if f.cancel != nil {
+		return fmt.Errorf("wait for or abort the current response first")
+	}
+	if name == f.SessionName {
+		f.saveSession()
+	}
+
+	if err := f.Sessions.Fork(name, newName); err != nil {
+		return err
+	}
+	if err := f.switchSession(newName, false); err != nil {
+		return err
+	}
+	f.closeSessionBrowser()
+	return nil
+}
+
+// 清空目前對話的狀態，回到剛啟動時的畫面
+func (f *Frame) resetSession() {
+	f.SessionName = ""
+	f.sessionParent = ""
+	f.sessionRecords = nil
+	f.CurrentSummary = NewSummary()
+	f.resetSummaryHistory(nil)
+	f.UserItems = nil
+	f.SessionUsage = Usage{}
+	f.ChatCost = 0
+	f.SummaryCost = 0
+	f.LastSearch = SearchReport{}
+
+	f.conversationLog.Reset()
+	f.writeGreeting()
+	f.Conversation.SetText(f.conversationLog.String())
+
+	if f.Comparer != nil {
+		f.Comparer.Restore(nil)
+	}
+
+	f.updateSummary()
+	f.updateRetrieval()
+	f.updateRecordTitle()
+}
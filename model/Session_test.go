@@ -0,0 +1,173 @@
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rivo/tview"
+)
+
+// 舊版 UI 沒有 Comparer，保存時不可清空已保存的記憶與 Embedder
+func TestSaveSessionWithoutComparer(t *testing.T) {
+	store := NewSessionStore(t.TempDir())
+	createdAt := time.Now().Add(-time.Hour)
+	err := store.Save(&Session{
+		Name:            "work",
+		CreatedAt:       createdAt,
+		UpdatedAt:       createdAt,
+		ConversationLog: "earlier turns\n",
+		Summary:         NewSummary(),
+		Records: []*ConversationRecord{
+			{ID: 1, User: "user", Content: "資料庫需要索引", TurnID: 1, Strength: 1},
+			{ID: 2, User: "assistant", Content: "建立 btree 索引", TurnID: 1, Strength: 1.5},
+		},
+		Embedder: "openai:text-embedding-3-small",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frame := &Frame{
+		Conversation: tview.NewTextView(),
+		Sessions:     store,
+		EmbedderID:   "hash",
+	}
+	if err := frame.OpenSession("work"); err != nil {
+		t.Fatal(err)
+	}
+	frame.saveSession()
+
+	session, err := store.Load("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(session.Records) != 2 || session.Records[1].Strength != 1.5 {
+		t.Errorf("records = %+v, want the two loaded records", session.Records)
+	}
+	if session.Embedder != "openai:text-embedding-3-small" {
+		t.Errorf("embedder = %q, want the loaded embedder", session.Embedder)
+	}
+	if !session.CreatedAt.Equal(createdAt) || !session.UpdatedAt.After(createdAt) {
+		t.Errorf("created %v, updated %v", session.CreatedAt, session.UpdatedAt)
+	}
+}
+
+func TestSaveSessionWithComparer(t *testing.T) {
+	store := NewSessionStore(t.TempDir())
+	frame := &Frame{
+		Conversation: tview.NewTextView(),
+		Comparer:     NewFuzzyComparer(0.3),
+		Sessions:     store,
+		EmbedderID:   "none",
+	}
+	if err := frame.OpenSession("work"); err != nil {
+		t.Fatal(err)
+	}
+	frame.Comparer.AddRecord("user", "預算上限是十萬元")
+	frame.saveSession()
+
+	session, err := store.Load("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(session.Records) != 1 || session.Records[0].Content != "預算上限是十萬元" {
+		t.Errorf("records = %+v", session.Records)
+	}
+	if session.Embedder != "none" {
+		t.Errorf("embedder = %q, want none", session.Embedder)
+	}
+}
+
+// 沿用保存的向量索引時，保存前尚未算完向量的記錄仍需補算
+func TestRestoreSessionEmbedsMissing(t *testing.T) {
+	store := NewSessionStore(t.TempDir())
+	err := store.Save(&Session{
+		Name:      "work",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		Summary:   NewSummary(),
+		Records: []*ConversationRecord{
+			{ID: 1, User: "user", Content: "資料庫需要索引", TurnID: 1, Strength: 1},
+			{ID: 2, User: "assistant", Content: "建立 btree 索引", TurnID: 1, Strength: 1},
+		},
+		Embedder: "hash",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	embedder := NewHashEmbedder(64)
+	vectorList, err := embedder.Embed(context.Background(), []string{"資料庫需要索引"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := NewVectorIndex()
+	index.Insert(1, vectorList[0])
+	if err := index.Save(store.VectorPath("work")); err != nil {
+		t.Fatal(err)
+	}
+
+	frame := &Frame{
+		Conversation: tview.NewTextView(),
+		Comparer:     NewFuzzyComparer(0.3, WithEmbedder(embedder)),
+		Sessions:     store,
+		EmbedderID:   "hash",
+	}
+	if err := frame.OpenSession("work"); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		recordList := frame.Comparer.Records()
+		if recordList[0].vector == nil {
+			t.Fatal("record 1 lost the loaded vector")
+		}
+		if recordList[1].vector != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("record 2 was not embedded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// 未命名的對話切換前需先保存，命名後即成為目前的對話
+func TestSaveUnnamedSession(t *testing.T) {
+	store := NewSessionStore(t.TempDir())
+	frame := &Frame{
+		Conversation: tview.NewTextView(),
+		Comparer:     NewFuzzyComparer(0.3),
+		Sessions:     store,
+		EmbedderID:   "none",
+	}
+	if frame.hasUnsavedConversation() {
+		t.Error("empty conversation reported as unsaved")
+	}
+
+	frame.Comparer.AddRecord("user", "預算上限是十萬元")
+	if !frame.hasUnsavedConversation() {
+		t.Fatal("conversation with records not reported as unsaved")
+	}
+
+	store.Save(&Session{Name: "work", Summary: NewSummary()})
+	if err := frame.saveUnnamedSession("work"); err == nil {
+		t.Error("saved over an existing session")
+	}
+	if err := frame.saveUnnamedSession("draft"); err != nil {
+		t.Fatal(err)
+	}
+	if frame.SessionName != "draft" || frame.hasUnsavedConversation() {
+		t.Errorf("session = %q, unsaved = %v", frame.SessionName, frame.hasUnsavedConversation())
+	}
+
+	session, err := store.Load("draft")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(session.Records) != 1 || session.CreatedAt.IsZero() {
+		t.Errorf("records = %d, created %v", len(session.Records), session.CreatedAt)
+	}
+}
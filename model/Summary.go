@@ -1,6 +1,9 @@
 package model
 
 import (
+	"encoding/json"
+	"errors"
+	"regexp"
 	"strings"
 )
 
@@ -15,26 +18,50 @@ type Summary struct {
 	PendingDiscussion []string `json:"pending_discussion"`
 }
 
+// 新對話的初始概要
+func NewSummary() Summary {
+	return Summary{
+		CoreDiscussion:    "empty",
+		ConfirmedNeeds:    []string{},
+		Constraints:       []string{},
+		ExcludedOptions:   []string{},
+		KeyData:           []string{},
+		CurrentConclusion: []string{},
+		PendingQuestions:  []string{},
+		PendingDiscussion: []string{},
+	}
+}
+
 func (s *Summary) FormatContent() string {
+	return s.formatContentMarked(nil)
+}
+
+// 使用者編輯的項目加上標記
+func (s *Summary) formatContentMarked(userItems map[string][]string) string {
 	var builder strings.Builder
 
 	builder.WriteString("[yellow]Core[white]\n")
 	builder.WriteString(s.CoreDiscussion + "\n\n")
 
-	addToContent(&builder, "Needs", s.ConfirmedNeeds)
-	addToContent(&builder, "Constraints", s.Constraints)
-	addToContent(&builder, "Exclude", s.ExcludedOptions)
-	addToContent(&builder, "Key Data", s.KeyData)
-	addToContent(&builder, "Current", s.CurrentConclusion)
-	addToContent(&builder, "Pending Questions", s.PendingQuestions)
-	addToContent(&builder, "Pending Discussions", s.PendingDiscussion)
+	for _, field := range s.fields() {
+		addToContent(&builder, field.Title, *field.Items, userItems[field.Key])
+	}
 
 	return builder.String()
 }
 
-func addToContent(builder *strings.Builder, title string, list []string) {
+func addToContent(builder *strings.Builder, title string, list, userList []string) {
+	userSet := make(map[string]bool, len(userList))
+	for _, item := range userList {
+		userSet[item] = true
+	}
+
 	builder.WriteString("[yellow]" + title + "[white]\n")
 	for _, item := range list {
+		if userSet[item] {
+			builder.WriteString("[aqua]✎[white] " + item + "\n")
+			continue
+		}
 		builder.WriteString("• " + item + "\n")
 	}
 	builder.WriteString("\n")
@@ -65,3 +92,33 @@ func addToContext(builder *strings.Builder, title string, list []string) {
 		}
 	}
 }
+
+var trailingCommaRegex = regexp.MustCompile(`,\s*([}\]])`)
+
+// 容錯解析：去除 code fence、擷取最外層物件、移除尾隨逗號後再試一次
+func parseSummary(content string) (Summary, error) {
+	var summary Summary
+
+	result := strings.TrimSpace(content)
+	result = strings.TrimPrefix(result, "```json")
+	result = strings.TrimPrefix(result, "```")
+	result = strings.TrimSuffix(result, "```")
+	result = strings.TrimSpace(result)
+
+	err := json.Unmarshal([]byte(result), &summary)
+	if err == nil {
+		return summary, nil
+	}
+
+	start := strings.Index(result, "{")
+	end := strings.LastIndex(result, "}")
+	if start < 0 || end <= start {
+		return summary, errors.New("no JSON object found in response")
+	}
+	repaired := trailingCommaRegex.ReplaceAllString(result[start:end+1], "$1")
+
+	if json.Unmarshal([]byte(repaired), &summary) != nil {
+		return summary, err
+	}
+	return summary, nil
+}
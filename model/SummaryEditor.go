@@ -0,0 +1,203 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const editorPage = "summary-editor"
+
+// 以純文字編輯概要：「## 標題」開始一個欄位，清單項目以「- 」開頭
+func formatSummaryText(summary Summary) string {
+	var builder strings.Builder
+
+	builder.WriteString("## Core\n")
+	builder.WriteString(summary.CoreDiscussion + "\n")
+
+	for _, field := range summary.fields() {
+		builder.WriteString("\n## " + field.Title + "\n")
+		for _, item := range *field.Items {
+			builder.WriteString("- " + item + "\n")
+		}
+	}
+
+	return builder.String()
+}
+
+// 解析編輯後的文字，缺少的欄位視為空清單，重複項目只保留一個
+func parseSummaryText(text string) (Summary, error) {
+	summary := NewSummary()
+	fieldList := summary.fields()
+
+	section := ""
+	var items *[]string
+	coreList := make([]string, 0)
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if title, ok := strings.CutPrefix(line, "##"); ok {
+			title = strings.TrimSpace(title)
+			section, items = "", nil
+			if strings.EqualFold(title, "Core") {
+				section = "core"
+				continue
+			}
+			for _, field := range fieldList {
+				if strings.EqualFold(title, field.Title) || title == field.Key {
+					section, items = field.Key, field.Items
+				}
+			}
+			if section == "" {
+				return summary, fmt.Errorf("line %d: unknown section %q", i+1, title)
+			}
+			continue
+		}
+
+		switch {
+		case section == "core":
+			coreList = append(coreList, line)
+		case items != nil:
+			item, ok := strings.CutPrefix(line, "-")
+			if !ok {
+				item, ok = strings.CutPrefix(line, "•")
+			}
+			if !ok {
+				return summary, fmt.Errorf("line %d: list items must start with \"- \"", i+1)
+			}
+			if item = strings.TrimSpace(item); item != "" && !containsItem(*items, item) {
+				*items = append(*items, item)
+			}
+		default:
+			return summary, fmt.Errorf("line %d: text outside a section", i+1)
+		}
+	}
+
+	summary.CoreDiscussion = strings.Join(coreList, " ")
+	if summary.CoreDiscussion == "" {
+		return summary, fmt.Errorf("core must not be empty")
+	}
+	return summary, nil
+}
+
+func containsItem(list []string, item string) bool {
+	for _, other := range list {
+		if other == item {
+			return true
+		}
+	}
+	return false
+}
+
+// 使用者新增或修改的項目標記為使用者編輯，刪除的項目取消標記
+func markUserItems(previous, edited Summary, userItems map[string][]string) map[string][]string {
+	markedList := make(map[string][]string)
+
+	previousFields := previous.fields()
+	for i, field := range edited.fields() {
+		for _, item := range *field.Items {
+			if containsItem(userItems[field.Key], item) || !containsItem(*previousFields[i].Items, item) {
+				markedList[field.Key] = append(markedList[field.Key], item)
+			}
+		}
+	}
+	return markedList
+}
+
+// 只保留仍存在於概要中的使用者項目，用於還原版本後
+func pruneUserItems(summary Summary, userItems map[string][]string) map[string][]string {
+	prunedList := make(map[string][]string)
+	for _, field := range summary.fields() {
+		for _, item := range userItems[field.Key] {
+			if containsItem(*field.Items, item) {
+				prunedList[field.Key] = append(prunedList[field.Key], item)
+			}
+		}
+	}
+	return prunedList
+}
+
+// 提示小模型使用者編輯的項目必須原文保留
+func formatUserItems(userItems map[string][]string) string {
+	var builder strings.Builder
+	for _, field := range (&Summary{}).fields() {
+		for _, item := range userItems[field.Key] {
+			builder.WriteString(fmt.Sprintf("- %s: %s\n", field.Label, item))
+		}
+	}
+	if builder.Len() == 0 {
+		return ""
+	}
+	return "\n使用者親自編輯的項目（必須原文保留在原欄位，不可刪除、合併或改寫）：\n" + builder.String()
+}
+
+// 開啟概要編輯器：Ctrl+S 套用、Esc 取消，生成中不允許編輯
+func (f *Frame) EditSummary() {
+	if f.pages == nil || f.pages.HasPage(editorPage) {
+		return
+	}
+	if f.cancel != nil {
+		f.AddToConversation(false, fmt.Sprintf("[yellow]%v[white]", "Summary"), "[yellow]wait for the current turn to finish before editing[white]")
+		return
+	}
+
+	title := " Edit summary · Ctrl+S apply · Esc cancel "
+	editor := tview.NewTextArea().
+		SetWrap(true).
+		SetWordWrap(true).
+		SetText(formatSummaryText(f.CurrentSummary), false)
+	editor.
+		SetBorder(true).
+		SetTitle(title).
+		SetTitleAlign(tview.AlignLeft)
+
+	closeEditor := func() {
+		f.pages.RemovePage(editorPage)
+		f.App.SetFocus(f.Summary)
+	}
+
+	editor.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyEscape:
+			closeEditor()
+			return nil
+		case tcell.KeyCtrlS:
+			edited, err := parseSummaryText(editor.GetText())
+			if err != nil {
+				editor.SetTitle(fmt.Sprintf(" Edit summary · [red]%v[white] ", tview.Escape(err.Error())))
+				return nil
+			}
+			if f.cancel != nil {
+				editor.SetTitle(" Edit summary · [red]wait for the current turn to finish[white] ")
+				return nil
+			}
+
+			closeEditor()
+			f.applySummaryEdit(edited)
+			return nil
+		}
+		return event
+	})
+
+	f.pages.AddPage(editorPage, centered(editor, 100, 36), true, true)
+	f.App.SetFocus(editor)
+}
+
+func (f *Frame) applySummaryEdit(edited Summary) {
+	diff := DiffSummary(f.CurrentSummary, edited)
+	f.UserItems = markUserItems(f.CurrentSummary, edited, f.UserItems)
+	f.setSummary(edited, "user")
+
+	added, removed := 0, 0
+	for _, field := range diff.FieldList {
+		added += len(field.Added)
+		removed += len(field.Removed)
+	}
+	f.AddToConversation(false, fmt.Sprintf("[grey]%v[white]", "Summary"), fmt.Sprintf("[grey]edited by user: %d added, %d removed[white]", added, removed))
+	f.saveSession()
+}
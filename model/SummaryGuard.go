@@ -0,0 +1,128 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 舊項目的詞彙有此比例出現在某個新項目中，即視為被該項目取代
+// 只用於配對取代的項目，數字或否定改變時詞彙仍大多相同，不能視為保留
+const summaryCoverThreshold = 0.6
+
+// 累加欄位中被相似的新項目取代的舊項目
+type SummaryReplacement struct {
+	Title  string `json:"title"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// 確定性合併：累加欄位中從上一版消失的項目補回新版末尾，回傳補回的項目
+// 只有 excluded_options 與 pending_questions 可以自由減少
+// 使用者編輯的項目不論欄位都必須原文保留
+// 只有相同（忽略空白差異）才算保留；與某個新項目相似的舊項目視為被改寫，
+// 不補回以免新舊說法並存，但回傳供記錄，讓「100 萬」改成「200 萬」這類變更不會無聲發生
+func mergeSummary(previous, next Summary, userItems map[string][]string) (Summary, []FieldDiff, []SummaryReplacement) {
+	merged := next
+	restoredList := make([]FieldDiff, 0)
+	replacedList := make([]SummaryReplacement, 0)
+
+	previousFields := previous.fields()
+	for i, field := range merged.fields() {
+		missingList := make([]string, 0)
+		for _, item := range *previousFields[i].Items {
+			if containsItem(userItems[field.Key], item) {
+				if !containsItem(*field.Items, item) {
+					missingList = append(missingList, item)
+				}
+				continue
+			}
+			if !field.Accumulate || keptIn(item, *field.Items) {
+				continue
+			}
+
+			if replacement, ok := replacedBy(item, *field.Items); ok {
+				replacedList = append(replacedList, SummaryReplacement{
+					Title:  field.Title,
+					Before: item,
+					After:  replacement,
+				})
+				continue
+			}
+			missingList = append(missingList, item)
+		}
+		if len(missingList) == 0 {
+			continue
+		}
+
+		// 複製避免修改模型回傳的原始切片
+		itemList := make([]string, 0, len(*field.Items)+len(missingList))
+		itemList = append(itemList, *field.Items...)
+		*field.Items = append(itemList, missingList...)
+		restoredList = append(restoredList, FieldDiff{
+			Key:   field.Key,
+			Title: field.Title,
+			Added: missingList,
+		})
+	}
+
+	return merged, restoredList, replacedList
+}
+
+// 項目相同，空白的位置與數量不同也視為相同
+func keptIn(item string, itemList []string) bool {
+	normalized := normalizeSpace(item)
+	for _, other := range itemList {
+		if normalizeSpace(other) == normalized {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeSpace(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// 找出詞彙重疊最多且達門檻的新項目
+func replacedBy(item string, itemList []string) (string, bool) {
+	wordList := tokenize(item)
+	if len(wordList) == 0 {
+		return "", false
+	}
+
+	best, bestRatio := "", 0.0
+	for _, other := range itemList {
+		otherSet := make(map[string]bool)
+		for _, word := range tokenize(other) {
+			otherSet[word] = true
+		}
+		count := 0
+		for _, word := range wordList {
+			if otherSet[word] {
+				count++
+			}
+		}
+		if ratio := float64(count) / float64(len(wordList)); ratio > bestRatio {
+			best, bestRatio = other, ratio
+		}
+	}
+	return best, bestRatio >= summaryCoverThreshold
+}
+
+func formatRestored(restoredList []FieldDiff) string {
+	partList := make([]string, 0, len(restoredList))
+	count := 0
+	for _, field := range restoredList {
+		count += len(field.Added)
+		partList = append(partList, fmt.Sprintf("%s: %s", field.Title, strings.Join(field.Added, "; ")))
+	}
+	return fmt.Sprintf("restored %d dropped item(s) | %s", count, strings.Join(partList, " | "))
+}
+
+func formatReplaced(replacedList []SummaryReplacement) string {
+	partList := make([]string, 0, len(replacedList))
+	for _, replaced := range replacedList {
+		partList = append(partList, fmt.Sprintf("%s: %s → %s", replaced.Title, replaced.Before, replaced.After))
+	}
+	return fmt.Sprintf("replaced %d item(s), press u in the Summary panel to undo | %s", len(replacedList), strings.Join(partList, " | "))
+}
@@ -0,0 +1,140 @@
+package model
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMergeSummary(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		previous     []string
+		next         []string
+		userItems    []string
+		wantItems    []string
+		wantRestored []string
+		wantReplaced []SummaryReplacement
+	}{
+		{
+			name:      "kept",
+			previous:  []string{"預算 100 萬元"},
+			next:      []string{"預算 100 萬元", "上線日期 3 月"},
+			wantItems: []string{"預算 100 萬元", "上線日期 3 月"},
+		},
+		{
+			name:      "whitespace only",
+			previous:  []string{"預算 100 萬元"},
+			next:      []string{" 預算  100 萬元"},
+			wantItems: []string{" 預算  100 萬元"},
+		},
+		{
+			name:         "dropped",
+			previous:     []string{"預算 100 萬元", "上線日期 3 月"},
+			next:         []string{"上線日期 3 月"},
+			wantItems:    []string{"上線日期 3 月", "預算 100 萬元"},
+			wantRestored: []string{"預算 100 萬元"},
+		},
+		// 數字改變時詞彙大多相同，不可無聲視為保留
+		{
+			name:         "number changed",
+			previous:     []string{"預算 100 萬元"},
+			next:         []string{"預算 200 萬元"},
+			wantItems:    []string{"預算 200 萬元"},
+			wantReplaced: []SummaryReplacement{{"Key Data", "預算 100 萬元", "預算 200 萬元"}},
+		},
+		{
+			name:         "negated",
+			previous:     []string{"需要支援離線模式"},
+			next:         []string{"不需要支援離線模式"},
+			wantItems:    []string{"不需要支援離線模式"},
+			wantReplaced: []SummaryReplacement{{"Key Data", "需要支援離線模式", "不需要支援離線模式"}},
+		},
+		{
+			name:         "english number changed",
+			previous:     []string{"max 10 concurrent users"},
+			next:         []string{"max 50 concurrent users"},
+			wantItems:    []string{"max 50 concurrent users"},
+			wantReplaced: []SummaryReplacement{{"Key Data", "max 10 concurrent users", "max 50 concurrent users"}},
+		},
+		// 使用者編輯的項目必須原文保留，不因相似的新項目被取代
+		{
+			name:         "user item",
+			previous:     []string{"預算 100 萬元"},
+			next:         []string{"預算 200 萬元"},
+			userItems:    []string{"預算 100 萬元"},
+			wantItems:    []string{"預算 200 萬元", "預算 100 萬元"},
+			wantRestored: []string{"預算 100 萬元"},
+		},
+	} {
+		previous, next := NewSummary(), NewSummary()
+		previous.KeyData = test.previous
+		next.KeyData = test.next
+		userItems := map[string][]string{"key_data": test.userItems}
+
+		merged, restoredList, replacedList := mergeSummary(previous, next, userItems)
+		if !slices.Equal(merged.KeyData, test.wantItems) {
+			t.Errorf("%s: items = %q, want %q", test.name, merged.KeyData, test.wantItems)
+		}
+
+		var restored []string
+		for _, field := range restoredList {
+			restored = append(restored, field.Added...)
+		}
+		if !slices.Equal(restored, test.wantRestored) {
+			t.Errorf("%s: restored = %q, want %q", test.name, restored, test.wantRestored)
+		}
+		if !slices.Equal(replacedList, test.wantReplaced) {
+			t.Errorf("%s: replaced = %+v, want %+v", test.name, replacedList, test.wantReplaced)
+		}
+	}
+}
+
+// 可自由減少的欄位不補回也不記錄取代
+func TestMergeSummaryShrinkableField(t *testing.T) {
+	previous, next := NewSummary(), NewSummary()
+	previous.PendingQuestions = []string{"預算上限是多少", "何時上線"}
+	next.PendingQuestions = []string{"預算上限是否含稅"}
+
+	merged, restoredList, replacedList := mergeSummary(previous, next, nil)
+	if !slices.Equal(merged.PendingQuestions, next.PendingQuestions) || len(restoredList) != 0 || len(replacedList) != 0 {
+		t.Errorf("pending = %q, restored = %+v, replaced = %+v", merged.PendingQuestions, restoredList, replacedList)
+	}
+}
+
+func TestKeptIn(t *testing.T) {
+	for _, test := range []struct {
+		item     string
+		itemList []string
+		want     bool
+	}{
+		{"預算 100 萬元", []string{"預算 100 萬元"}, true},
+		{"預算 100 萬元", []string{"預算\t100  萬元 "}, true},
+		{"預算 100 萬元", []string{"預算 200 萬元"}, false},
+		{"需要支援離線模式", []string{"不需要支援離線模式"}, false},
+		{"PostgreSQL 16", []string{"postgresql 16"}, false},
+		{"預算 100 萬元", nil, false},
+	} {
+		if got := keptIn(test.item, test.itemList); got != test.want {
+			t.Errorf("keptIn(%q, %q) = %v, want %v", test.item, test.itemList, got, test.want)
+		}
+	}
+}
+
+func TestReplacedBy(t *testing.T) {
+	for _, test := range []struct {
+		item     string
+		itemList []string
+		want     string
+		wantOK   bool
+	}{
+		{"預算 100 萬元", []string{"上線日期 3 月", "預算 200 萬元"}, "預算 200 萬元", true},
+		{"需要支援離線模式", []string{"不需要支援離線模式"}, "不需要支援離線模式", true},
+		{"預算 100 萬元", []string{"上線日期 3 月"}, "", false},
+		{"", []string{"預算 100 萬元"}, "", false},
+	} {
+		got, ok := replacedBy(test.item, test.itemList)
+		if ok != test.wantOK || (ok && got != test.want) {
+			t.Errorf("replacedBy(%q, %q) = %q, %v, want %q, %v", test.item, test.itemList, got, ok, test.want, test.wantOK)
+		}
+	}
+}
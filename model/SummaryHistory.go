@@ -0,0 +1,277 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// 保留的版本上限，超過時捨棄最舊的版本
+const maxSummaryHistory = 50
+
+// 每次更新概要保存一個版本，復原也新增版本，超過上限時才捨棄最舊的版本
+type SummaryVersion struct {
+	At time.Time `json:"at"`
+	// model、undo、restore 等更新來源
+	Source  string  `json:"source"`
+	Summary Summary `json:"summary"`
+	// 由第幾版（從 1 開始）還原而來，0 代表新產生的版本
+	RestoredFrom int `json:"restored_from,omitempty"`
+}
+
+type summaryField struct {
+	Key   string
+	Title string
+	// 提示中使用的中文名稱
+	Label string
+	Items *[]string
+	// 只能累加的欄位，模型更新時遺漏的項目會被補回
+	Accumulate bool
+}
+
+// 概要中的清單欄位，依面板顯示順序
+func (s *Summary) fields() []summaryField {
+	return []summaryField{
+		{"confirmed_needs", "Needs", "確認需求", &s.ConfirmedNeeds, true},
+		{"constraints", "Constraints", "約束條件", &s.Constraints, true},
+		{"excluded_options", "Exclude", "排除項目", &s.ExcludedOptions, false},
+		{"key_data", "Key Data", "關鍵資料", &s.KeyData, true},
+		{"current_conclusion", "Current", "最新結論", &s.CurrentConclusion, true},
+		{"pending_questions", "Pending Questions", "待釐清項目", &s.PendingQuestions, false},
+		{"pending_discussion", "Pending Discussions", "過往討論", &s.PendingDiscussion, true},
+	}
+}
+
+type FieldDiff struct {
+	Key     string   `json:"key"`
+	Title   string   `json:"title"`
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+type SummaryDiff struct {
+	CoreBefore string      `json:"core_before"`
+	CoreAfter  string      `json:"core_after"`
+	FieldList  []FieldDiff `json:"fields"`
+}
+
+// 比較兩個版本各清單欄位新增與移除的項目，不考慮順序
+func DiffSummary(before, after Summary) SummaryDiff {
+	diff := SummaryDiff{
+		CoreBefore: before.CoreDiscussion,
+		CoreAfter:  after.CoreDiscussion,
+	}
+
+	beforeFields := before.fields()
+	for i, field := range after.fields() {
+		diff.FieldList = append(diff.FieldList, FieldDiff{
+			Key:     field.Key,
+			Title:   field.Title,
+			Added:   missingItems(*field.Items, *beforeFields[i].Items),
+			Removed: missingItems(*beforeFields[i].Items, *field.Items),
+		})
+	}
+	return diff
+}
+
+// 在 list 中但不在 other 中的項目
+func missingItems(list, other []string) []string {
+	existsList := make(map[string]bool, len(other))
+	for _, item := range other {
+		existsList[item] = true
+	}
+
+	missingList := make([]string, 0)
+	for _, item := range list {
+		if !existsList[item] {
+			missingList = append(missingList, item)
+		}
+	}
+	return missingList
+}
+
+func (d *SummaryDiff) FormatContent() string {
+	var builder strings.Builder
+
+	builder.WriteString("[yellow]Core[white]\n")
+	if d.CoreBefore == d.CoreAfter {
+		builder.WriteString(tview.Escape(d.CoreAfter) + "\n\n")
+	} else {
+		builder.WriteString("[red]- " + tview.Escape(d.CoreBefore) + "[white]\n")
+		builder.WriteString("[green]+ " + tview.Escape(d.CoreAfter) + "[white]\n\n")
+	}
+
+	changed := false
+	for _, field := range d.FieldList {
+		if len(field.Added) == 0 && len(field.Removed) == 0 {
+			continue
+		}
+		changed = true
+
+		builder.WriteString("[yellow]" + field.Title + "[white]\n")
+		for _, item := range field.Removed {
+			builder.WriteString("[red]- " + tview.Escape(item) + "[white]\n")
+		}
+		for _, item := range field.Added {
+			builder.WriteString("[green]+ " + tview.Escape(item) + "[white]\n")
+		}
+		builder.WriteString("\n")
+	}
+	if !changed {
+		builder.WriteString("[grey]no list changes[white]\n")
+	}
+
+	return builder.String()
+}
+
+// 設定新概要並記錄版本，需在 UI goroutine 呼叫
+// 內容未改變時（例如生成失敗或中斷）不新增版本，回傳是否新增
+func (f *Frame) setSummary(summary Summary, source string) bool {
+	if len(f.SummaryHistory) > 0 && sameSummary(f.CurrentSummary, summary) {
+		return false
+	}
+
+	f.CurrentSummary = summary
+	f.SummaryHistory = append(f.SummaryHistory, SummaryVersion{
+		At:      time.Now(),
+		Source:  source,
+		Summary: summary,
+	})
+
+	// 捨棄最舊的版本，還原來源的版本編號隨之前移，來源已捨棄時視為新產生
+	if dropped := len(f.SummaryHistory) - maxSummaryHistory; dropped > 0 {
+		f.SummaryHistory = slices.Clone(f.SummaryHistory[dropped:])
+		for i := range f.SummaryHistory {
+			f.SummaryHistory[i].RestoredFrom = max(f.SummaryHistory[i].RestoredFrom-dropped, 0)
+		}
+	}
+
+	f.summaryCursor = len(f.SummaryHistory) - 1
+	f.updateSummary()
+	return true
+}
+
+// nil 與空清單視為相同
+func sameSummary(a, b Summary) bool {
+	if a.CoreDiscussion != b.CoreDiscussion {
+		return false
+	}
+	bFields := b.fields()
+	for i, field := range a.fields() {
+		if !slices.Equal(*field.Items, *bFields[i].Items) {
+			return false
+		}
+	}
+	return true
+}
+
+// 以指定版本作為起點的歷史，用於新對話與還原
+func (f *Frame) resetSummaryHistory(history []SummaryVersion) {
+	if len(history) == 0 {
+		history = []SummaryVersion{{
+			At:      time.Now(),
+			Source:  "initial",
+			Summary: f.CurrentSummary,
+		}}
+	}
+	f.SummaryHistory = history
+	f.summaryCursor = len(history) - 1
+	f.summaryDiff = false
+}
+
+// Summary 面板依瀏覽的版本與模式顯示內容或差異
+func (f *Frame) updateSummary() {
+	if len(f.SummaryHistory) == 0 {
+		f.Summary.SetText(f.CurrentSummary.FormatContent())
+		f.Summary.SetTitle(" Summary ")
+		return
+	}
+
+	cursor := min(max(f.summaryCursor, 0), len(f.SummaryHistory)-1)
+	version := f.SummaryHistory[cursor]
+
+	mode := ""
+	if f.summaryDiff {
+		mode = " · diff"
+		before := Summary{}
+		if cursor > 0 {
+			before = f.SummaryHistory[cursor-1].Summary
+		}
+		diff := DiffSummary(before, version.Summary)
+		f.Summary.SetText(diff.FormatContent())
+	} else {
+		f.Summary.SetText(version.Summary.formatContentMarked(f.UserItems))
+	}
+
+	latest := ""
+	if cursor != len(f.SummaryHistory)-1 {
+		latest = " · [yellow]viewing history[white]"
+	}
+	f.Summary.SetTitle(fmt.Sprintf(" Summary · v%d/%d %s%s%s ", cursor+1, len(f.SummaryHistory), version.Source, mode, latest))
+}
+
+// Summary 面板取得焦點時：e 編輯、d 切換差異、[ ] 瀏覽版本、u 復原上一版、Enter 還原瀏覽中的版本
+func (f *Frame) bindSummaryKeys() {
+	f.Summary.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEnter {
+			f.restoreSummary(f.summaryCursor, "restore")
+			return nil
+		}
+
+		switch event.Rune() {
+		case 'e':
+			f.EditSummary()
+			return nil
+		case 'd':
+			f.summaryDiff = !f.summaryDiff
+		case '[':
+			f.summaryCursor = max(f.summaryCursor-1, 0)
+		case ']':
+			f.summaryCursor = min(f.summaryCursor+1, len(f.SummaryHistory)-1)
+		case 'u':
+			f.restoreSummary(f.undoTarget(), "undo")
+			return nil
+		default:
+			return event
+		}
+
+		f.updateSummary()
+		f.Summary.ScrollToBeginning()
+		return nil
+	})
+}
+
+// 復原的目標為目前版本的前一版；目前版本由還原而來時，改為其來源的前一版，連續復原才會一路往回
+func (f *Frame) undoTarget() int {
+	last := f.SummaryHistory[len(f.SummaryHistory)-1]
+	if last.RestoredFrom > 0 {
+		return last.RestoredFrom - 2
+	}
+	return len(f.SummaryHistory) - 2
+}
+
+// 將指定版本設為目前概要並新增一個版本，生成概要期間不允許
+func (f *Frame) restoreSummary(index int, source string) {
+	if index < 0 || index >= len(f.SummaryHistory) || index == len(f.SummaryHistory)-1 {
+		return
+	}
+	if f.cancel != nil {
+		f.AddToConversation(false, fmt.Sprintf("[yellow]%v[white]", "Summary"), "[yellow]wait for the current turn to finish before restoring[white]")
+		return
+	}
+
+	// 捨棄舊版本後編號會前移，先記下與最新版本的距離
+	offset := len(f.SummaryHistory) - index
+	f.UserItems = pruneUserItems(f.SummaryHistory[index].Summary, f.UserItems)
+	if !f.setSummary(f.SummaryHistory[index].Summary, fmt.Sprintf("%s v%d", source, index+1)) {
+		f.AddToConversation(false, fmt.Sprintf("[grey]%v[white]", "Summary"), fmt.Sprintf("[grey]version %d matches the current summary[white]", index+1))
+		return
+	}
+	f.SummaryHistory[len(f.SummaryHistory)-1].RestoredFrom = max(len(f.SummaryHistory)-offset, 0)
+	f.AddToConversation(false, fmt.Sprintf("[grey]%v[white]", "Summary"), fmt.Sprintf("[grey]restored version %d[white]", index+1))
+	f.saveSession()
+}
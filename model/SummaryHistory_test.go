@@ -0,0 +1,75 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/rivo/tview"
+)
+
+func newTestSummaryFrame() *Frame {
+	frame := &Frame{
+		Conversation:   tview.NewTextView(),
+		Summary:        tview.NewTextView(),
+		CurrentSummary: NewSummary(),
+	}
+	frame.resetSummaryHistory(nil)
+	return frame
+}
+
+func TestSetSummarySkipsUnchanged(t *testing.T) {
+	frame := newTestSummaryFrame()
+
+	summary := NewSummary()
+	summary.CoreDiscussion = "資料庫索引"
+	summary.KeyData = []string{"PostgreSQL 16"}
+	if !frame.setSummary(summary, "model") {
+		t.Fatal("changed summary not added")
+	}
+
+	// 生成失敗時回傳原本的概要，nil 與空清單視為相同
+	unchanged := summary
+	unchanged.ConfirmedNeeds = nil
+	if frame.setSummary(unchanged, "model") {
+		t.Error("unchanged summary added a version")
+	}
+	if len(frame.SummaryHistory) != 2 {
+		t.Errorf("history = %d versions, want 2", len(frame.SummaryHistory))
+	}
+}
+
+func TestSetSummaryCapsHistory(t *testing.T) {
+	frame := newTestSummaryFrame()
+
+	for i := 1; i <= maxSummaryHistory+10; i++ {
+		summary := NewSummary()
+		summary.CoreDiscussion = fmt.Sprintf("topic %d", i)
+		frame.setSummary(summary, "model")
+	}
+	if len(frame.SummaryHistory) != maxSummaryHistory {
+		t.Fatalf("history = %d versions, want %d", len(frame.SummaryHistory), maxSummaryHistory)
+	}
+	if frame.summaryCursor != maxSummaryHistory-1 {
+		t.Errorf("cursor = %d, want latest", frame.summaryCursor)
+	}
+	if core := frame.SummaryHistory[0].Summary.CoreDiscussion; core != "topic 11" {
+		t.Errorf("oldest kept = %q, want topic 11", core)
+	}
+
+	// 還原後版本編號隨捨棄的舊版本前移
+	frame.restoreSummary(maxSummaryHistory-3, "restore")
+	last := frame.SummaryHistory[len(frame.SummaryHistory)-1]
+	if len(frame.SummaryHistory) != maxSummaryHistory {
+		t.Fatalf("history = %d versions, want %d", len(frame.SummaryHistory), maxSummaryHistory)
+	}
+	source := frame.SummaryHistory[last.RestoredFrom-1].Summary
+	if !sameSummary(source, last.Summary) || !sameSummary(frame.CurrentSummary, last.Summary) {
+		t.Errorf("restored from v%d = %q, want %q", last.RestoredFrom, source.CoreDiscussion, last.Summary.CoreDiscussion)
+	}
+
+	// 復原回到還原前的上一版
+	frame.restoreSummary(frame.undoTarget(), "undo")
+	if core := frame.CurrentSummary.CoreDiscussion; core != fmt.Sprintf("topic %d", maxSummaryHistory+7) {
+		t.Errorf("after undo = %q, want topic %d", core, maxSummaryHistory+7)
+	}
+}
@@ -0,0 +1,98 @@
+package model
+
+import (
+	"strings"
+	"unicode"
+)
+
+// 停用詞列表
+var stopList = map[string]bool{
+	"的": true, "是": true, "在": true, "有": true, "和": true,
+	"與": true, "或": true, "但": true, "這": true, "那": true,
+	"我": true, "你": true, "他": true, "她": true, "它": true,
+	"了": true, "嗎": true, "呢": true, "啊": true, "吧": true,
+	"the": true, "is": true, "at": true, "which": true, "on": true,
+	"and": true, "or": true, "but": true, "this": true, "that": true,
+	"i": true, "you": true, "he": true, "she": true, "it": true,
+}
+
+// 中英混合斷詞：英文與數字以連續字元為一詞，中日韓文字以雙字切分（bigram）
+// 全形英數會先轉為半形，全形與半形標點皆視為分隔
+func tokenize(text string) []string {
+	tokenList := make([]string, 0)
+	var word strings.Builder
+	cjkRun := make([]rune, 0)
+
+	flushWord := func() {
+		if word.Len() > 0 {
+			tokenList = append(tokenList, word.String())
+			word.Reset()
+		}
+	}
+	flushCJK := func() {
+		tokenList = append(tokenList, cjkBigram(cjkRun)...)
+		cjkRun = cjkRun[:0]
+	}
+
+	for _, r := range text {
+		r = foldWidth(r)
+
+		switch {
+		case isCJK(r):
+			flushWord()
+			cjkRun = append(cjkRun, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word.WriteRune(unicode.ToLower(r))
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+
+	return tokenList
+}
+
+// 單字停用詞（如「的」「了」）在中文裡沒有空白隔開，先以其切開再做 bigram
+func cjkBigram(run []rune) []string {
+	tokenList := make([]string, 0, len(run))
+
+	start := 0
+	for i := 0; i <= len(run); i++ {
+		if i < len(run) && !stopList[string(run[i])] {
+			continue
+		}
+
+		segment := run[start:i]
+		if len(segment) == 1 {
+			tokenList = append(tokenList, string(segment))
+		}
+		for j := 0; j+1 < len(segment); j++ {
+			tokenList = append(tokenList, string(segment[j:j+2]))
+		}
+		start = i + 1
+	}
+
+	return tokenList
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}
+
+// 全形 ASCII（！到～）轉半形，全形空白轉一般空白
+func foldWidth(r rune) rune {
+	switch {
+	case r >= 0xFF01 && r <= 0xFF5E:
+		return r - 0xFEE0
+	case r == 0x3000:
+		return ' '
+	default:
+		return r
+	}
+}
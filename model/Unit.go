@@ -2,6 +2,9 @@ package model
 
 var (
 	ApiKey                  string
+	AnthropicApiKey         string
+	GeminiApiKey            string
+	OllamaApiKey            string
 	InstructionConversation string
 	InstructionSummary      string
 )
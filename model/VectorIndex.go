@@ -0,0 +1,483 @@
+package model
+
+import (
+	"encoding/gob"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// HNSW（Hierarchical Navigable Small World）近似最近鄰索引
+// 上層稀疏用於快速定位，第 0 層包含所有節點；刪除採標記方式，節點仍可作為路徑
+// 節點以內部連續編號存放，鄰居也記錄內部編號，避免搜尋時大量查詢 map
+type VectorIndex struct {
+	mu             sync.RWMutex
+	M              int
+	EfConstruction int
+	EfSearch       int
+	levelMult      float64
+	nodeList       []*vectorNode
+	idIndex        map[int]int
+	entry          int
+	maxLevel       int
+	rng            *rand.Rand
+	visitedPool    sync.Pool
+}
+
+type vectorNode struct {
+	ID        int
+	Vector    []float32
+	Neighbors [][]int
+	Deleted   bool
+}
+
+type VectorMatch struct {
+	ID         int
+	Similarity float64
+}
+
+func NewVectorIndex() *VectorIndex {
+	m := 16
+	return &VectorIndex{
+		M:              m,
+		EfConstruction: 100,
+		EfSearch:       100,
+		levelMult:      1 / math.Log(float64(m)),
+		nodeList:       make([]*vectorNode, 0),
+		idIndex:        make(map[int]int),
+		entry:          -1,
+		rng:            rand.New(rand.NewSource(1)),
+	}
+}
+
+func (v *VectorIndex) Len() int {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return len(v.idIndex)
+}
+
+// 回傳已正規化的向量，不存在時為 nil
+func (v *VectorIndex) Vector(id int) []float32 {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if index, ok := v.idIndex[id]; ok {
+		return v.nodeList[index].Vector
+	}
+	return nil
+}
+
+func (v *VectorIndex) Insert(id int, vector []float32) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	// 重複插入視為更新：舊節點標記刪除後以新向量建立新節點
+	if old, ok := v.idIndex[id]; ok {
+		v.nodeList[old].Deleted = true
+	}
+
+	// 存入正規化後的副本，距離只需計算內積
+	vector = normalizedCopy(vector)
+	level := int(math.Floor(-math.Log(1-v.rng.Float64()) * v.levelMult))
+	node := &vectorNode{
+		ID:        id,
+		Vector:    vector,
+		Neighbors: make([][]int, level+1),
+	}
+	index := len(v.nodeList)
+	v.nodeList = append(v.nodeList, node)
+	v.idIndex[id] = index
+
+	if v.entry < 0 {
+		v.entry = index
+		v.maxLevel = level
+		return
+	}
+
+	entry := v.entry
+	for l := v.maxLevel; l > level; l-- {
+		entry = v.searchLayer(vector, []int{entry}, 1, l)[0].index
+	}
+
+	entryList := []int{entry}
+	for l := min(level, v.maxLevel); l >= 0; l-- {
+		candidateList := v.searchLayer(vector, entryList, v.EfConstruction, l)
+
+		neighborList := v.selectNeighbors(candidateList, v.maxNeighbors(l))
+		node.Neighbors[l] = neighborList
+
+		for _, neighbor := range neighborList {
+			v.connect(v.nodeList[neighbor], index, l)
+		}
+
+		entryList = entryList[:0]
+		for _, c := range candidateList {
+			entryList = append(entryList, c.index)
+		}
+	}
+
+	if level > v.maxLevel {
+		v.entry = index
+		v.maxLevel = level
+	}
+}
+
+// 第 0 層包含所有節點，允許兩倍的連結數
+func (v *VectorIndex) maxNeighbors(level int) int {
+	if level == 0 {
+		return v.M * 2
+	}
+	return v.M
+}
+
+// 啟發式選擇鄰居：候選若離已選鄰居比離目標更近則略過，保留不同方向的連結
+// 不足上限時再以略過的候選補滿
+func (v *VectorIndex) selectNeighbors(candidateList []vectorCandidate, limit int) []int {
+	selectedList := make([]vectorCandidate, 0, limit)
+	skippedList := make([]vectorCandidate, 0)
+
+	for _, c := range candidateList {
+		if len(selectedList) >= limit {
+			break
+		}
+
+		vector := v.nodeList[c.index].Vector
+		keep := true
+		for _, selected := range selectedList {
+			if vectorDistance(vector, v.nodeList[selected.index].Vector) < c.distance {
+				keep = false
+				break
+			}
+		}
+
+		if keep {
+			selectedList = append(selectedList, c)
+		} else {
+			skippedList = append(skippedList, c)
+		}
+	}
+
+	for _, c := range skippedList {
+		if len(selectedList) >= limit {
+			break
+		}
+		selectedList = append(selectedList, c)
+	}
+
+	indexList := make([]int, 0, len(selectedList))
+	for _, c := range selectedList {
+		indexList = append(indexList, c.index)
+	}
+	return indexList
+}
+
+// 加入反向連結，超過上限時同樣以啟發式選擇保留的鄰居
+// 只保留最近的鄰居會剪掉跨群聚的連結，主題集中的資料會分裂成互不相連的區塊
+func (v *VectorIndex) connect(node *vectorNode, index, level int) {
+	node.Neighbors[level] = append(node.Neighbors[level], index)
+
+	limit := v.maxNeighbors(level)
+	if len(node.Neighbors[level]) <= limit {
+		return
+	}
+
+	candidateList := make([]vectorCandidate, 0, len(node.Neighbors[level]))
+	for _, neighbor := range node.Neighbors[level] {
+		candidateList = append(candidateList, vectorCandidate{
+			index:    neighbor,
+			distance: vectorDistance(node.Vector, v.nodeList[neighbor].Vector),
+		})
+	}
+	sort.Slice(candidateList, func(i, j int) bool {
+		return candidateList[i].distance < candidateList[j].distance
+	})
+
+	node.Neighbors[level] = v.selectNeighbors(candidateList, limit)
+}
+
+func (v *VectorIndex) Delete(id int) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if index, ok := v.idIndex[id]; ok {
+		v.nodeList[index].Deleted = true
+		delete(v.idIndex, id)
+	}
+}
+
+// 回傳最相似的 k 筆，相似度為餘弦相似度
+func (v *VectorIndex) Search(query []float32, k int) []VectorMatch {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if v.entry < 0 || k <= 0 {
+		return nil
+	}
+	query = normalizedCopy(query)
+
+	entry := v.entry
+	for l := v.maxLevel; l > 0; l-- {
+		entry = v.searchLayer(query, []int{entry}, 1, l)[0].index
+	}
+
+	// 已刪除的節點會被過濾，多取一些避免結果不足
+	candidateList := v.searchLayer(query, []int{entry}, max(v.EfSearch, k*2), 0)
+
+	matchList := make([]VectorMatch, 0, k)
+	for _, c := range candidateList {
+		node := v.nodeList[c.index]
+		if node.Deleted {
+			continue
+		}
+		matchList = append(matchList, VectorMatch{
+			ID:         node.ID,
+			Similarity: 1 - c.distance,
+		})
+		if len(matchList) >= k {
+			break
+		}
+	}
+	return matchList
+}
+
+// 以世代編號標記已造訪節點，每次搜尋只需遞增世代而不必重新配置或清空
+// 搜尋在讀鎖下可能同時進行，緩衝區經由 sync.Pool 各自取用
+type visitedSet struct {
+	marks []uint32
+	epoch uint32
+}
+
+func (v *VectorIndex) acquireVisited() *visitedSet {
+	visited, _ := v.visitedPool.Get().(*visitedSet)
+	if visited == nil {
+		visited = &visitedSet{}
+	}
+	if len(visited.marks) < len(v.nodeList) {
+		marks := make([]uint32, len(v.nodeList)+len(v.nodeList)/4)
+		copy(marks, visited.marks)
+		visited.marks = marks
+	}
+	visited.epoch++
+	if visited.epoch == 0 {
+		// 世代編號溢位時清空，避免與舊標記混淆
+		clear(visited.marks)
+		visited.epoch = 1
+	}
+	return visited
+}
+
+// 標記節點為已造訪，已造訪過則回傳 false
+func (s *visitedSet) visit(index int) bool {
+	if s.marks[index] == s.epoch {
+		return false
+	}
+	s.marks[index] = s.epoch
+	return true
+}
+
+// 在指定層做貪婪的最佳優先搜尋，回傳依距離由近到遠排序的 ef 個節點
+func (v *VectorIndex) searchLayer(query []float32, entryList []int, ef, level int) []vectorCandidate {
+	visited := v.acquireVisited()
+	defer v.visitedPool.Put(visited)
+	candidateHeap := &vectorHeap{items: make([]vectorCandidate, 0, ef)}
+	resultHeap := &vectorHeap{items: make([]vectorCandidate, 0, ef+1), reverse: true}
+
+	for _, index := range entryList {
+		if !visited.visit(index) {
+			continue
+		}
+		c := vectorCandidate{
+			index:    index,
+			distance: vectorDistance(query, v.nodeList[index].Vector),
+		}
+		candidateHeap.push(c)
+		resultHeap.push(c)
+	}
+
+	for candidateHeap.Len() > 0 {
+		current := candidateHeap.pop()
+		if resultHeap.Len() >= ef && current.distance > resultHeap.items[0].distance {
+			break
+		}
+
+		node := v.nodeList[current.index]
+		if level >= len(node.Neighbors) {
+			continue
+		}
+		for _, neighbor := range node.Neighbors[level] {
+			if !visited.visit(neighbor) {
+				continue
+			}
+
+			distance := vectorDistance(query, v.nodeList[neighbor].Vector)
+			if resultHeap.Len() < ef || distance < resultHeap.items[0].distance {
+				c := vectorCandidate{
+					index:    neighbor,
+					distance: distance,
+				}
+				candidateHeap.push(c)
+				resultHeap.push(c)
+				if resultHeap.Len() > ef {
+					resultHeap.pop()
+				}
+			}
+		}
+	}
+
+	resultList := make([]vectorCandidate, resultHeap.Len())
+	for i := len(resultList) - 1; i >= 0; i-- {
+		resultList[i] = resultHeap.pop()
+	}
+	return resultList
+}
+
+// 向量皆已正規化，1 - 內積即為餘弦距離
+func vectorDistance(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 2
+	}
+
+	// 分成四組累加，讓編譯器不必等待前一次加法完成
+	var dot0, dot1, dot2, dot3 float32
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		dot0 += a[i] * b[i]
+		dot1 += a[i+1] * b[i+1]
+		dot2 += a[i+2] * b[i+2]
+		dot3 += a[i+3] * b[i+3]
+	}
+	for ; i < len(a); i++ {
+		dot0 += a[i] * b[i]
+	}
+	return 1 - float64(dot0+dot1+dot2+dot3)
+}
+
+func normalizedCopy(vector []float32) []float32 {
+	result := make([]float32, len(vector))
+	copy(result, vector)
+	normalize(result)
+	return result
+}
+
+type vectorCandidate struct {
+	index    int
+	distance float64
+}
+
+// reverse 為 true 時為最大堆積，用於保留目前最近的 ef 筆
+// 不經由 container/heap 以免每次 Push 把元素裝箱成 interface 而配置記憶體
+type vectorHeap struct {
+	items   []vectorCandidate
+	reverse bool
+}
+
+func (h *vectorHeap) Len() int { return len(h.items) }
+
+func (h *vectorHeap) less(i, j int) bool {
+	if h.reverse {
+		return h.items[i].distance > h.items[j].distance
+	}
+	return h.items[i].distance < h.items[j].distance
+}
+
+func (h *vectorHeap) push(c vectorCandidate) {
+	h.items = append(h.items, c)
+	for i := len(h.items) - 1; i > 0; {
+		parent := (i - 1) / 2
+		if !h.less(i, parent) {
+			break
+		}
+		h.items[i], h.items[parent] = h.items[parent], h.items[i]
+		i = parent
+	}
+}
+
+func (h *vectorHeap) pop() vectorCandidate {
+	top := h.items[0]
+	last := len(h.items) - 1
+	h.items[0] = h.items[last]
+	h.items = h.items[:last]
+	for i := 0; ; {
+		smallest := i
+		for _, child := range []int{2*i + 1, 2*i + 2} {
+			if child < last && h.less(child, smallest) {
+				smallest = child
+			}
+		}
+		if smallest == i {
+			break
+		}
+		h.items[i], h.items[smallest] = h.items[smallest], h.items[i]
+		i = smallest
+	}
+	return top
+}
+
+type vectorIndexFile struct {
+	M              int
+	EfConstruction int
+	EfSearch       int
+	NodeList       []*vectorNode
+	Entry          int
+	MaxLevel       int
+}
+
+// 先寫入暫存檔再改名，避免中途結束留下損壞的索引
+func (v *VectorIndex) Save(path string) error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = gob.NewEncoder(tmp).Encode(vectorIndexFile{
+		M:              v.M,
+		EfConstruction: v.EfConstruction,
+		EfSearch:       v.EfSearch,
+		NodeList:       v.nodeList,
+		Entry:          v.entry,
+		MaxLevel:       v.maxLevel,
+	})
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func LoadVectorIndex(path string) (*VectorIndex, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var data vectorIndexFile
+	if err := gob.NewDecoder(file).Decode(&data); err != nil {
+		return nil, err
+	}
+
+	index := NewVectorIndex()
+	index.M = data.M
+	index.EfConstruction = data.EfConstruction
+	index.EfSearch = data.EfSearch
+	index.levelMult = 1 / math.Log(float64(data.M))
+	index.entry = data.Entry
+	index.maxLevel = data.MaxLevel
+	index.nodeList = data.NodeList
+	for i, node := range data.NodeList {
+		if !node.Deleted {
+			index.idIndex[node.ID] = i
+		}
+	}
+	return index, nil
+}
@@ -0,0 +1,235 @@
+package model
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// 圍繞隨機中心產生的向量，接近實際 embedding 的主題群聚
+// 實際 embedding 集中在低維度的子空間，先在 16 維產生再投影到 dim 維並加上少量雜訊
+// 若每一維都是獨立雜訊，最近鄰幾乎與其他點等距，任何近似索引都只能掃過大半資料
+func syntheticVectors(count, dim int, seed int64) [][]float32 {
+	const latentDim = 16
+	// 投影與群聚中心固定，不同 seed 產生的資料與查詢才在同一個空間
+	base := rand.New(rand.NewSource(0))
+	projection := make([][]float32, dim)
+	for i := range projection {
+		projection[i] = make([]float32, latentDim)
+		for j := range projection[i] {
+			projection[i][j] = float32(base.NormFloat64() / 4)
+		}
+	}
+	centerList := make([][]float32, 32)
+	for i := range centerList {
+		centerList[i] = make([]float32, latentDim)
+		for j := range centerList[i] {
+			centerList[i][j] = float32(base.NormFloat64())
+		}
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	vectorList := make([][]float32, 0, count)
+	latent := make([]float32, latentDim)
+	for i := 0; i < count; i++ {
+		center := centerList[rng.Intn(len(centerList))]
+		for j := range latent {
+			latent[j] = center[j] + float32(rng.NormFloat64()*0.5)
+		}
+		vector := make([]float32, dim)
+		for j := range vector {
+			var value float32
+			for l, x := range latent {
+				value += projection[j][l] * x
+			}
+			vector[j] = value + float32(rng.NormFloat64()*0.05)
+		}
+		vectorList = append(vectorList, vector)
+	}
+	return vectorList
+}
+
+// 記錄 ID 從 1 開始，與 Comparer 一致
+func newTestVectorIndex(vectorList [][]float32) *VectorIndex {
+	index := NewVectorIndex()
+	for i, vector := range vectorList {
+		index.Insert(i+1, vector)
+	}
+	return index
+}
+
+// 以 cosineSimilarity 逐一比較的精確結果
+func bruteForceSearch(vectorList [][]float32, deletedList map[int]bool, query []float32, k int) []VectorMatch {
+	matchList := make([]VectorMatch, 0, len(vectorList))
+	for i, vector := range vectorList {
+		if deletedList[i+1] {
+			continue
+		}
+		matchList = append(matchList, VectorMatch{ID: i + 1, Similarity: cosineSimilarity(query, vector)})
+	}
+	sort.Slice(matchList, func(i, j int) bool {
+		return matchList[i].Similarity > matchList[j].Similarity
+	})
+	return matchList[:min(k, len(matchList))]
+}
+
+func recallAtK(index *VectorIndex, vectorList, queryList [][]float32, deletedList map[int]bool, k int) float64 {
+	hit, total := 0, 0
+	for _, query := range queryList {
+		foundList := make(map[int]bool, k)
+		for _, match := range index.Search(query, k) {
+			foundList[match.ID] = true
+		}
+		for _, match := range bruteForceSearch(vectorList, deletedList, query, k) {
+			if foundList[match.ID] {
+				hit++
+			}
+			total++
+		}
+	}
+	return float64(hit) / float64(total)
+}
+
+func TestVectorIndexRecall(t *testing.T) {
+	vectorList := syntheticVectors(5000, 64, 1)
+	queryList := syntheticVectors(100, 64, 2)
+	index := newTestVectorIndex(vectorList)
+
+	if index.Len() != len(vectorList) {
+		t.Fatalf("len = %d, want %d", index.Len(), len(vectorList))
+	}
+	if recall := recallAtK(index, vectorList, queryList, nil, 10); recall < 0.9 {
+		t.Errorf("recall@10 = %.3f, want >= 0.9", recall)
+	}
+
+	// 相似度與 cosineSimilarity 一致
+	match := index.Search(vectorList[42], 1)
+	if len(match) != 1 || match[0].ID != 43 || match[0].Similarity < 0.999 {
+		t.Errorf("self search = %+v, want #43 with similarity 1", match)
+	}
+}
+
+func TestVectorIndexDelete(t *testing.T) {
+	vectorList := syntheticVectors(2000, 32, 3)
+	queryList := syntheticVectors(50, 32, 4)
+	index := newTestVectorIndex(vectorList)
+
+	deletedList := make(map[int]bool)
+	for id := 1; id <= len(vectorList); id += 3 {
+		index.Delete(id)
+		deletedList[id] = true
+	}
+	// 重複刪除或不存在的 ID 不影響
+	index.Delete(1)
+	index.Delete(len(vectorList) + 1)
+
+	if index.Len() != len(vectorList)-len(deletedList) {
+		t.Errorf("len = %d, want %d", index.Len(), len(vectorList)-len(deletedList))
+	}
+	if index.Vector(1) != nil {
+		t.Error("deleted vector is still returned")
+	}
+
+	for _, query := range queryList {
+		matchList := index.Search(query, 20)
+		if len(matchList) != 20 {
+			t.Fatalf("got %d matches, want 20", len(matchList))
+		}
+		for _, match := range matchList {
+			if deletedList[match.ID] {
+				t.Fatalf("deleted #%d returned", match.ID)
+			}
+		}
+	}
+
+	if recall := recallAtK(index, vectorList, queryList, deletedList, 10); recall < 0.9 {
+		t.Errorf("recall@10 after delete = %.3f, want >= 0.9", recall)
+	}
+}
+
+func TestVectorIndexSaveLoad(t *testing.T) {
+	vectorList := syntheticVectors(1000, 32, 5)
+	queryList := syntheticVectors(20, 32, 6)
+	index := newTestVectorIndex(vectorList)
+	index.Delete(7)
+
+	path := filepath.Join(t.TempDir(), vectorFile)
+	if err := index.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadVectorIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.Len() != index.Len() {
+		t.Errorf("len = %d, want %d", loaded.Len(), index.Len())
+	}
+	if loaded.Vector(7) != nil {
+		t.Error("deleted vector restored")
+	}
+	for _, query := range queryList {
+		want := index.Search(query, 10)
+		got := loaded.Search(query, 10)
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("search after load = %v, want %v", got, want)
+		}
+	}
+
+	// 載入後仍可繼續新增
+	loaded.Insert(len(vectorList)+1, vectorList[0])
+	if match := loaded.Search(vectorList[0], 2); len(match) != 2 {
+		t.Errorf("search after insert = %+v", match)
+	}
+}
+
+func TestLoadVectorIndexMissing(t *testing.T) {
+	if _, err := LoadVectorIndex(filepath.Join(t.TempDir(), vectorFile)); err == nil {
+		t.Error("want error for missing file")
+	}
+}
+
+func BenchmarkVectorIndexSearch(b *testing.B) {
+	for _, size := range []int{10000, 100000} {
+		// 索引在子測試內才建立，以 -bench 篩選時不會建立用不到的索引
+		// b.Run 的函式會以不同 b.N 執行多次，建立一次後重複使用，建立時間不計入
+		var index *VectorIndex
+		var vectorList, queryList [][]float32
+		var recall float64
+
+		b.Run(fmt.Sprintf("vectors=%d", size), func(b *testing.B) {
+			if index == nil {
+				vectorList = syntheticVectors(size, 128, 1)
+				queryList = syntheticVectors(100, 128, 2)
+				index = newTestVectorIndex(vectorList)
+				recall = recallAtK(index, vectorList, queryList, nil, 10)
+				b.ResetTimer()
+			}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				index.Search(queryList[i%len(queryList)], vectorCandidateCount)
+			}
+			b.ReportMetric(recall, "recall@10")
+		})
+	}
+}
+
+func BenchmarkVectorIndexBruteForce(b *testing.B) {
+	for _, size := range []int{10000, 100000} {
+		var vectorList, queryList [][]float32
+
+		b.Run(fmt.Sprintf("vectors=%d", size), func(b *testing.B) {
+			if vectorList == nil {
+				vectorList = syntheticVectors(size, 128, 1)
+				queryList = syntheticVectors(100, 128, 2)
+				b.ResetTimer()
+			}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				bruteForceSearch(vectorList, nil, queryList[i%len(queryList)], vectorCandidateCount)
+			}
+		})
+	}
+}